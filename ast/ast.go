@@ -2,9 +2,39 @@ package ast
 
 import (
 	"fmt"
+	"monkey/token"
 )
 
 type Node interface {
 	TokenLiteral() string
+	Pos() token.Position // ノードの最初の文字の位置
+	End() token.Position // ノードの最後の文字の直後の位置
 	fmt.Stringer
 }
+
+// 子ノードの開始位置を返す
+// 構文解析エラーで子ノードが欠けている場合はトークンの開始位置を返す
+func posOf(node Node, tok *token.Token) token.Position {
+	if node == nil {
+		return tok.Pos()
+	}
+	return node.Pos()
+}
+
+// 子ノードの直後の位置を返す
+// 構文解析エラーで子ノードが欠けている場合はトークンの直後の位置を返す
+func endOf(node Node, tok *token.Token) token.Position {
+	if node == nil {
+		return tok.End()
+	}
+	return node.End()
+}
+
+// 閉じる括弧の直後の位置を返す
+// 構文解析エラーで閉じる括弧がない場合は開く括弧の直後の位置を返す
+func closingEnd(open *token.Token, close *token.Token) token.Position {
+	if close == nil {
+		return open.End()
+	}
+	return close.End()
+}
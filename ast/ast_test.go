@@ -1,8 +1,14 @@
 package ast_test
 
 import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +33,236 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong: got = %q", program.String())
 	}
 }
+
+func TestLoopString(t *testing.T) {
+	tests := []struct {
+		node ast.Node
+		want string
+	}{
+		{parser.NewParser(lexer.NewLexer("while (i < 3) { i }")).ParseProgram().Statements[0], "while(i < 3) i"},
+		{parser.NewParser(lexer.NewLexer("for (x in a + b) { x }")).ParseProgram().Statements[0], "for (x in (a + b)) x"},
+		// 構文解析エラーで欠けた子ノードがあってもpanicしない
+		{&ast.WhileStatement{Token: token.NewToken(token.WHILE, "while")}, "while "},
+		{&ast.ForStatement{Token: token.NewToken(token.FOR, "for")}, "for ( in ) "},
+	}
+
+	for _, tt := range tests {
+		if got := tt.node.String(); got != tt.want {
+			t.Errorf("wrong String(). want=%q, got=%q", tt.want, got)
+		}
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return ast.NewIntegerLiteralByValue(1) }
+	two := func() ast.Expression { return ast.NewIntegerLiteralByValue(2) }
+	block := func(exp ast.Expression) *ast.BlockStatement {
+		return &ast.BlockStatement{
+			Token:      token.NewToken(token.LBRACE, "{"),
+			Statements: []ast.Statement{&ast.ExpressionStatement{Expression: exp}},
+		}
+	}
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	cases := []struct {
+		input ast.Node
+		want  ast.Node
+	}{
+		{one(), two()},
+		{
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: one()},
+			&ast.PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&ast.IndexExpression{Left: one(), Index: one()},
+			&ast.IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&ast.IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&ast.IfExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{
+			&ast.ReturnStatement{ReturnValue: one()},
+			&ast.ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&ast.LetStatement{Value: one()},
+			&ast.LetStatement{Value: two()},
+		},
+		{
+			&ast.FunctionLiteral{Parameters: []*ast.Identifier{}, Body: block(one())},
+			&ast.FunctionLiteral{Parameters: []*ast.Identifier{}, Body: block(two())},
+		},
+		{
+			&ast.CallExpression{Function: ast.NewIdentifierByName("f"), Arguments: []ast.Expression{one(), one()}},
+			&ast.CallExpression{Function: ast.NewIdentifierByName("f"), Arguments: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}},
+			&ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.AssignExpression{Target: ast.NewIdentifierByName("x"), Operator: "+=", Value: one()},
+			&ast.AssignExpression{Target: ast.NewIdentifierByName("x"), Operator: "+=", Value: two()},
+		},
+		{
+			&ast.WhileStatement{Condition: one(), Body: block(one())},
+			&ast.WhileStatement{Condition: two(), Body: block(two())},
+		},
+		{
+			&ast.ForStatement{Variable: ast.NewIdentifierByName("x"), Iterable: one(), Body: block(one())},
+			&ast.ForStatement{Variable: ast.NewIdentifierByName("x"), Iterable: two(), Body: block(two())},
+		},
+		{
+			&ast.MacroLiteral{Parameters: []*ast.Identifier{}, Body: block(one())},
+			&ast.MacroLiteral{Parameters: []*ast.Identifier{}, Body: block(two())},
+		},
+		{
+			&ast.IfExpression{Condition: one(), Consequence: block(one())},
+			&ast.IfExpression{Condition: two(), Consequence: block(two())},
+		},
+		{
+			&ast.HashLiteral{Pairs: []ast.HashPair{{Key: one(), Value: one()}, {Key: one(), Value: one()}}},
+			&ast.HashLiteral{Pairs: []ast.HashPair{{Key: two(), Value: two()}, {Key: two(), Value: two()}}},
+		},
+	}
+
+	for _, tc := range cases {
+		got := ast.Modify(tc.input, turnOneIntoTwo)
+		if diff := cmp.Diff(got, tc.want, cmpopts.IgnoreUnexported(token.Token{})); diff != "" {
+			t.Errorf("failed to modify %q, diff (-got +want):\n%s", tc.input, diff)
+		}
+	}
+}
+
+func TestModifyKeepsInput(t *testing.T) {
+	input := `
+let f = fn(x) { if (x == 1) { [1, {1: 1}][0] } else if (x) { -1 } };
+for (y in [1]) { while (y) { y += 1; } }
+f(1);
+`
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+	before := program.String()
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		if integer, ok := node.(*ast.IntegerLiteral); ok && integer.Value == 1 {
+			return ast.NewIntegerLiteralByValue(2)
+		}
+		return node
+	}
+	modified := ast.Modify(program, turnOneIntoTwo)
+
+	if got := program.String(); got != before {
+		t.Errorf("input was modified. got=%q, want=%q", got, before)
+	}
+	want := strings.ReplaceAll(before, "1", "2")
+	if got := modified.String(); got != want {
+		t.Errorf("wrong result. got=%q, want=%q", got, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	input := `
+let add = fn(a, b) { return a + b; };
+let h = {"k": [1, 2][0]};
+if (add(1, 2) > 2) { h["k"] = -1 } else { 0 }
+for (x in [1]) { while (true) { break; } }
+`
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+
+	var identifiers []string
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		if ident, ok := node.(*ast.Identifier); ok {
+			identifiers = append(identifiers, ident.Value)
+		}
+		return true
+	})
+
+	want := []string{"add", "a", "b", "a", "b", "h", "add", "h", "x"}
+	if diff := cmp.Diff(identifiers, want); diff != "" {
+		t.Errorf("wrong identifiers, diff (-got +want):\n%s", diff)
+	}
+	if depth != 0 {
+		t.Errorf("Visit(nil) is not called for each visited node. depth=%d", depth)
+	}
+
+	// falseを返すと子ノードは訪問しない
+	var visited []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier:
+			visited = append(visited, node.Value)
+		}
+		return true
+	})
+
+	want = []string{"add", "h", "add", "h", "x"}
+	if diff := cmp.Diff(visited, want); diff != "" {
+		t.Errorf("wrong identifiers, diff (-got +want):\n%s", diff)
+	}
+}
+
+type nodeCounter map[string]int
+
+func (c nodeCounter) Visit(node ast.Node) ast.Visitor {
+	if node != nil {
+		c[fmt.Sprintf("%T", node)]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	input := `let m = macro(x) { quote(unquote(x)) }; let a = [1, 2.5, "s", true]; a[0] += {1: 2}[1];`
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+
+	counter := nodeCounter{}
+	ast.Walk(counter, program)
+
+	want := nodeCounter{
+		"*ast.Program":             1,
+		"*ast.LetStatement":        2,
+		"*ast.ExpressionStatement": 2,
+		"*ast.MacroLiteral":        1,
+		"*ast.BlockStatement":      1,
+		"*ast.CallExpression":      2,
+		"*ast.Identifier":          7,
+		"*ast.ArrayLiteral":        1,
+		"*ast.IntegerLiteral":      5,
+		"*ast.FloatLiteral":        1,
+		"*ast.StringLiteral":       1,
+		"*ast.Boolean":             1,
+		"*ast.AssignExpression":    1,
+		"*ast.IndexExpression":     2,
+		"*ast.HashLiteral":         1,
+	}
+	if diff := cmp.Diff(counter, want); diff != "" {
+		t.Errorf("wrong node counts, diff (-got +want):\n%s", diff)
+	}
+}
@@ -36,13 +36,27 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos()
+}
+
+func (i *Identifier) End() token.Position {
+	return i.Token.End()
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
 
 type HashLiteral struct {
-	Token *token.Token // '[' トークン
-	Pairs map[Expression]Expression
+	Token  *token.Token // '{' トークン
+	Pairs  []HashPair   // ソースコードに書いた順に並ぶ
+	Rbrace *token.Token // '}' トークン
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 var _ Expression = (*HashLiteral)(nil)
@@ -50,12 +64,16 @@ var _ Expression = (*HashLiteral)(nil)
 func NewHashLiteral(token *token.Token) *HashLiteral {
 	return &HashLiteral{
 		Token: token,
-		Pairs: map[Expression]Expression{},
+		Pairs: []HashPair{},
 	}
 }
 
 func (l *HashLiteral) AddPair(key Expression, value Expression) {
-	l.Pairs[key] = value
+	l.Pairs = append(l.Pairs, HashPair{Key: key, Value: value})
+}
+
+func (l *HashLiteral) SetRbrace(rbrace *token.Token) {
+	l.Rbrace = rbrace
 }
 
 func (l *HashLiteral) expressionNode() {}
@@ -64,11 +82,19 @@ func (l *HashLiteral) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *HashLiteral) Pos() token.Position {
+	return l.Token.Pos()
+}
+
+func (l *HashLiteral) End() token.Position {
+	return closingEnd(l.Token, l.Rbrace)
+}
+
 func (l *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range l.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range l.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -80,6 +106,7 @@ func (l *HashLiteral) String() string {
 type ArrayLiteral struct {
 	Token    *token.Token // '[' トークン
 	Elements []Expression
+	Rbracket *token.Token // ']' トークン
 }
 
 var _ Expression = (*ArrayLiteral)(nil)
@@ -94,12 +121,24 @@ func (a *ArrayLiteral) SetElements(elements []Expression) {
 	a.Elements = elements
 }
 
+func (a *ArrayLiteral) SetRbracket(rbracket *token.Token) {
+	a.Rbracket = rbracket
+}
+
 func (a *ArrayLiteral) expressionNode() {}
 
 func (a *ArrayLiteral) TokenLiteral() string {
 	return a.Token.Literal
 }
 
+func (a *ArrayLiteral) Pos() token.Position {
+	return a.Token.Pos()
+}
+
+func (a *ArrayLiteral) End() token.Position {
+	return closingEnd(a.Token, a.Rbracket)
+}
+
 func (a *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -114,9 +153,10 @@ func (a *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token *token.Token // '[' トークン
-	Left  Expression
-	Index Expression
+	Token    *token.Token // '[' トークン
+	Left     Expression
+	Index    Expression
+	Rbracket *token.Token // ']' トークン
 }
 
 var _ Expression = (*IndexExpression)(nil)
@@ -132,12 +172,24 @@ func (e *IndexExpression) SetIndex(index Expression) {
 	e.Index = index
 }
 
+func (e *IndexExpression) SetRbracket(rbracket *token.Token) {
+	e.Rbracket = rbracket
+}
+
 func (e *IndexExpression) expressionNode() {}
 
 func (e *IndexExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *IndexExpression) Pos() token.Position {
+	return posOf(e.Left, e.Token)
+}
+
+func (e *IndexExpression) End() token.Position {
+	return closingEnd(e.Token, e.Rbracket)
+}
+
 func (e *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -173,6 +225,14 @@ func (s *StringLiteral) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *StringLiteral) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *StringLiteral) End() token.Position {
+	return s.Token.End()
+}
+
 func (s *StringLiteral) String() string {
 	return s.Token.Literal
 }
@@ -201,10 +261,50 @@ func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IntegerLiteral) Pos() token.Position {
+	return i.Token.Pos()
+}
+
+func (i *IntegerLiteral) End() token.Position {
+	return i.Token.End()
+}
+
 func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token *token.Token // token.FLOAT トークン
+	Value float64
+}
+
+var _ Expression = (*FloatLiteral)(nil)
+
+func NewFloatLiteral(token *token.Token, value float64) *FloatLiteral {
+	return &FloatLiteral{
+		Token: token,
+		Value: value,
+	}
+}
+
+func (f *FloatLiteral) expressionNode() {}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) Pos() token.Position {
+	return f.Token.Pos()
+}
+
+func (f *FloatLiteral) End() token.Position {
+	return f.Token.End()
+}
+
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
 type Boolean struct {
 	Token *token.Token
 	Value bool
@@ -229,6 +329,14 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos()
+}
+
+func (b *Boolean) End() token.Position {
+	return b.Token.End()
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -258,6 +366,14 @@ func (e *PrefixExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *PrefixExpression) Pos() token.Position {
+	return e.Token.Pos()
+}
+
+func (e *PrefixExpression) End() token.Position {
+	return endOf(e.Right, e.Token)
+}
+
 func (e *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -295,6 +411,14 @@ func (e *InfixExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *InfixExpression) Pos() token.Position {
+	return posOf(e.Left, e.Token)
+}
+
+func (e *InfixExpression) End() token.Position {
+	return endOf(e.Right, e.Token)
+}
+
 func (e *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -306,6 +430,52 @@ func (e *InfixExpression) String() string {
 	return out.String()
 }
 
+// 代入式
+// Targetは識別子か添字式で、複合代入の場合Operatorは「+=」などになる
+type AssignExpression struct {
+	Token    *token.Token // 代入演算子トークン
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+var _ Expression = (*AssignExpression)(nil)
+
+func NewAssignExpression(token *token.Token, target Expression) *AssignExpression {
+	return &AssignExpression{
+		Token:    token,
+		Operator: token.Literal,
+		Target:   target,
+	}
+}
+
+func (e *AssignExpression) SetValue(value Expression) {
+	e.Value = value
+}
+
+func (e *AssignExpression) expressionNode() {}
+
+func (e *AssignExpression) TokenLiteral() string {
+	return e.Token.Literal
+}
+
+func (e *AssignExpression) Pos() token.Position {
+	return posOf(e.Target, e.Token)
+}
+
+func (e *AssignExpression) End() token.Position {
+	return endOf(e.Value, e.Token)
+}
+
+func (e *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(e.Target.String())
+	out.WriteString(" " + e.Operator + " ")
+	out.WriteString(e.Value.String())
+
+	return out.String()
+}
+
 type IfExpression struct {
 	Token       *token.Token // 'if' トークン
 	Condition   Expression
@@ -333,12 +503,44 @@ func (e *IfExpression) SetAlternative(bs *BlockStatement) {
 	e.Alternative = bs
 }
 
+// else if で連結された後続のif式を返す
+// else if は、後続のif式だけを含むブロックとしてAlternativeに格納される
+// ブロックのトークンがifトークンと同じかどうかで else { if ... } と区別する
+func (e *IfExpression) ElseIf() *IfExpression {
+	if e.Alternative == nil || len(e.Alternative.Statements) != 1 {
+		return nil
+	}
+	stmt, ok := e.Alternative.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	next, ok := stmt.Expression.(*IfExpression)
+	if !ok || next.Token != e.Alternative.Token {
+		return nil
+	}
+	return next
+}
+
 func (e *IfExpression) expressionNode() {}
 
 func (e *IfExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *IfExpression) Pos() token.Position {
+	return e.Token.Pos()
+}
+
+func (e *IfExpression) End() token.Position {
+	if e.Alternative != nil {
+		return e.Alternative.End()
+	}
+	if e.Consequence != nil {
+		return e.Consequence.End()
+	}
+	return e.Token.End()
+}
+
 func (e *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -346,7 +548,10 @@ func (e *IfExpression) String() string {
 	out.WriteString(" ")
 	out.WriteString(e.Consequence.String())
 
-	if e.Alternative != nil {
+	if next := e.ElseIf(); next != nil {
+		out.WriteString("else ")
+		out.WriteString(next.String())
+	} else if e.Alternative != nil {
 		out.WriteString("else")
 		out.WriteString(e.Alternative.String())
 	}
@@ -383,6 +588,17 @@ func (l *FunctionLiteral) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *FunctionLiteral) Pos() token.Position {
+	return l.Token.Pos()
+}
+
+func (l *FunctionLiteral) End() token.Position {
+	if l.Body != nil {
+		return l.Body.End()
+	}
+	return l.Token.End()
+}
+
 func (l *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -400,10 +616,68 @@ func (l *FunctionLiteral) String() string {
 	return out.String()
 }
 
+type MacroLiteral struct {
+	Token      *token.Token // 'macro' トークン
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+var _ Expression = (*MacroLiteral)(nil)
+
+func NewMacroLiteral(token *token.Token) *MacroLiteral {
+	return &MacroLiteral{
+		Token:      token,
+		Parameters: []*Identifier{},
+	}
+}
+
+func (l *MacroLiteral) SetParameters(parameters []*Identifier) {
+	l.Parameters = parameters
+}
+
+func (l *MacroLiteral) SetBody(body *BlockStatement) {
+	l.Body = body
+}
+
+func (l *MacroLiteral) expressionNode() {}
+
+func (l *MacroLiteral) TokenLiteral() string {
+	return l.Token.Literal
+}
+
+func (l *MacroLiteral) Pos() token.Position {
+	return l.Token.Pos()
+}
+
+func (l *MacroLiteral) End() token.Position {
+	if l.Body != nil {
+		return l.Body.End()
+	}
+	return l.Token.End()
+}
+
+func (l *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, parameter := range l.Parameters {
+		params = append(params, parameter.String())
+	}
+
+	out.WriteString(l.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ","))
+	out.WriteString(")")
+	out.WriteString(l.Body.String())
+
+	return out.String()
+}
+
 type CallExpression struct {
 	Token     *token.Token // '(' トークン
 	Function  Expression   // Identifier または FunctionLiteral
 	Arguments []Expression
+	Rparen    *token.Token // ')' トークン
 }
 
 var _ Expression = (*CallExpression)(nil)
@@ -423,12 +697,24 @@ func (e *CallExpression) SetArguments(arguments []Expression) {
 	e.Arguments = arguments
 }
 
+func (e *CallExpression) SetRparen(rparen *token.Token) {
+	e.Rparen = rparen
+}
+
 func (e *CallExpression) expressionNode() {}
 
 func (e *CallExpression) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *CallExpression) Pos() token.Position {
+	return posOf(e.Function, e.Token)
+}
+
+func (e *CallExpression) End() token.Position {
+	return closingEnd(e.Token, e.Rparen)
+}
+
 func (e *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -0,0 +1,183 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// 子ノードから順にmodifierを適用し、戻り値で置き換えた新しい構文木を返す
+// マクロ展開のように、構文木の一部を別のノードに書き換えるために使う
+// ノードはすべてコピーしてからmodifierに渡すので、引数の構文木は変更しない
+// modifierが別の型のノードを返して親に格納できない場合、その子ノードはnilになる
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		copied := *node
+		copied.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&copied)
+	case *ExpressionStatement:
+		copied := *node
+		copied.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&copied)
+	case *LetStatement:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *ReturnStatement:
+		copied := *node
+		copied.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		return modifier(&copied)
+	case *BlockStatement:
+		copied := *node
+		copied.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&copied)
+	case *WhileStatement:
+		copied := *node
+		copied.Condition = modifyExpression(node.Condition, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *ForStatement:
+		copied := *node
+		copied.Variable = modifyIdentifier(node.Variable, modifier)
+		copied.Iterable = modifyExpression(node.Iterable, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *BreakStatement:
+		copied := *node
+		return modifier(&copied)
+	case *ContinueStatement:
+		copied := *node
+		return modifier(&copied)
+	case *Identifier:
+		copied := *node
+		return modifier(&copied)
+	case *IntegerLiteral:
+		copied := *node
+		return modifier(&copied)
+	case *FloatLiteral:
+		copied := *node
+		return modifier(&copied)
+	case *StringLiteral:
+		copied := *node
+		return modifier(&copied)
+	case *Boolean:
+		copied := *node
+		return modifier(&copied)
+	case *PrefixExpression:
+		copied := *node
+		copied.Right = modifyExpression(node.Right, modifier)
+		return modifier(&copied)
+	case *InfixExpression:
+		copied := *node
+		copied.Left = modifyExpression(node.Left, modifier)
+		copied.Right = modifyExpression(node.Right, modifier)
+		return modifier(&copied)
+	case *AssignExpression:
+		copied := *node
+		copied.Target = modifyExpression(node.Target, modifier)
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *IfExpression:
+		copied := *node
+		copied.Condition = modifyExpression(node.Condition, modifier)
+		copied.Consequence = modifyBlock(node.Consequence, modifier)
+		copied.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&copied)
+	case *FunctionLiteral:
+		copied := *node
+		copied.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *MacroLiteral:
+		copied := *node
+		copied.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *CallExpression:
+		copied := *node
+		copied.Function = modifyExpression(node.Function, modifier)
+		copied.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&copied)
+	case *IndexExpression:
+		copied := *node
+		copied.Left = modifyExpression(node.Left, modifier)
+		copied.Index = modifyExpression(node.Index, modifier)
+		return modifier(&copied)
+	case *ArrayLiteral:
+		copied := *node
+		copied.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&copied)
+	case *HashLiteral:
+		copied := *node
+		if node.Pairs != nil {
+			copied.Pairs = make([]HashPair, len(node.Pairs))
+			for i, pair := range node.Pairs {
+				copied.Pairs[i] = HashPair{
+					Key:   modifyExpression(pair.Key, modifier),
+					Value: modifyExpression(pair.Value, modifier),
+				}
+			}
+		}
+		return modifier(&copied)
+	}
+
+	return modifier(node)
+}
+
+// 構文解析エラーで欠けた子ノードはnilのままにする
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	modified, _ := Modify(exp, modifier).(Expression)
+	return modified
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	if exps == nil {
+		return nil
+	}
+	modified := make([]Expression, len(exps))
+	for i, exp := range exps {
+		modified[i] = modifyExpression(exp, modifier)
+	}
+	return modified
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	if statements == nil {
+		return nil
+	}
+	modified := make([]Statement, len(statements))
+	for i, statement := range statements {
+		if statement != nil {
+			modified[i], _ = Modify(statement, modifier).(Statement)
+		}
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	modified, _ := Modify(block, modifier).(*BlockStatement)
+	return modified
+}
+
+func modifyIdentifier(identifier *Identifier, modifier ModifierFunc) *Identifier {
+	if identifier == nil {
+		return nil
+	}
+	modified, _ := Modify(identifier, modifier).(*Identifier)
+	return modified
+}
+
+func modifyIdentifiers(identifiers []*Identifier, modifier ModifierFunc) []*Identifier {
+	if identifiers == nil {
+		return nil
+	}
+	modified := make([]*Identifier, len(identifiers))
+	for i, identifier := range identifiers {
+		modified[i] = modifyIdentifier(identifier, modifier)
+	}
+	return modified
+}
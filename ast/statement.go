@@ -18,15 +18,13 @@ type LetStatement struct {
 
 var _ Statement = (*LetStatement)(nil)
 
-func NewLetStatement(name *Identifier) *LetStatement {
+func NewLetStatement(token *token.Token, name *Identifier) *LetStatement {
 	return &LetStatement{
-		Token: letToken,
+		Token: token,
 		Name:  name,
 	}
 }
 
-var letToken = token.NewToken(token.LET, "let")
-
 func (s *LetStatement) SetValue(value Expression) {
 	s.Value = value
 }
@@ -37,6 +35,14 @@ func (s *LetStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *LetStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *LetStatement) End() token.Position {
+	return endOf(s.Value, s.Token)
+}
+
 func (s *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -59,14 +65,12 @@ type ReturnStatement struct {
 
 var _ Statement = (*ReturnStatement)(nil)
 
-func NewReturnStatement() *ReturnStatement {
+func NewReturnStatement(token *token.Token) *ReturnStatement {
 	return &ReturnStatement{
-		Token: returnToken,
+		Token: token,
 	}
 }
 
-var returnToken = token.NewToken(token.RETURN, "return")
-
 func (s *ReturnStatement) SetReturnValue(value Expression) {
 	s.ReturnValue = value
 }
@@ -77,6 +81,14 @@ func (s *ReturnStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *ReturnStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *ReturnStatement) End() token.Position {
+	return endOf(s.ReturnValue, s.Token)
+}
+
 func (s *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -113,6 +125,14 @@ func (s *ExpressionStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *ExpressionStatement) Pos() token.Position {
+	return posOf(s.Expression, s.Token)
+}
+
+func (s *ExpressionStatement) End() token.Position {
+	return endOf(s.Expression, s.Token)
+}
+
 func (s *ExpressionStatement) String() string {
 	if s.Expression != nil {
 		return s.Expression.String()
@@ -122,8 +142,9 @@ func (s *ExpressionStatement) String() string {
 }
 
 type BlockStatement struct {
-	*token.Token // 式の最初のトークン
+	*token.Token // '{' トークン（else if の場合は 'if' トークン）
 	Statements   []Statement
+	Rbrace       *token.Token // '}' トークン
 }
 
 var _ Statement = (*BlockStatement)(nil)
@@ -139,12 +160,31 @@ func (s *BlockStatement) AddStatement(statement Statement) {
 	s.Statements = append(s.Statements, statement)
 }
 
+func (s *BlockStatement) SetRbrace(rbrace *token.Token) {
+	s.Rbrace = rbrace
+}
+
 func (s *BlockStatement) statementNode() {}
 
 func (s *BlockStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *BlockStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *BlockStatement) End() token.Position {
+	if s.Rbrace != nil {
+		return s.Rbrace.End()
+	}
+	// else if のブロックには閉じる'}'がないので、最後の文の終わりとする
+	if len(s.Statements) > 0 {
+		return s.Statements[len(s.Statements)-1].End()
+	}
+	return s.Token.End()
+}
+
 func (s *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, statement := range s.Statements {
@@ -152,3 +192,182 @@ func (s *BlockStatement) String() string {
 	}
 	return out.String()
 }
+
+type WhileStatement struct {
+	*token.Token // token.WHILE トークン
+	Condition    Expression
+	Body         *BlockStatement
+}
+
+var _ Statement = (*WhileStatement)(nil)
+
+func NewWhileStatement(token *token.Token) *WhileStatement {
+	return &WhileStatement{
+		Token: token,
+	}
+}
+
+func (s *WhileStatement) SetCondition(condition Expression) {
+	s.Condition = condition
+}
+
+func (s *WhileStatement) SetBody(body *BlockStatement) {
+	s.Body = body
+}
+
+func (s *WhileStatement) statementNode() {}
+
+func (s *WhileStatement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *WhileStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *WhileStatement) End() token.Position {
+	if s.Body != nil {
+		return s.Body.End()
+	}
+	return s.Token.End()
+}
+
+func (s *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	// 条件式は中置式なら自分で括弧をつけるので、ifと同じくそのまま出力する
+	out.WriteString(s.TokenLiteral())
+	if s.Condition != nil {
+		out.WriteString(s.Condition.String())
+	}
+	out.WriteString(" ")
+	if s.Body != nil {
+		out.WriteString(s.Body.String())
+	}
+
+	return out.String()
+}
+
+// for (x in iterable) { ... }
+type ForStatement struct {
+	*token.Token // token.FOR トークン
+	Variable     *Identifier
+	Iterable     Expression
+	Body         *BlockStatement
+}
+
+var _ Statement = (*ForStatement)(nil)
+
+func NewForStatement(token *token.Token) *ForStatement {
+	return &ForStatement{
+		Token: token,
+	}
+}
+
+func (s *ForStatement) SetVariable(variable *Identifier) {
+	s.Variable = variable
+}
+
+func (s *ForStatement) SetIterable(iterable Expression) {
+	s.Iterable = iterable
+}
+
+func (s *ForStatement) SetBody(body *BlockStatement) {
+	s.Body = body
+}
+
+func (s *ForStatement) statementNode() {}
+
+func (s *ForStatement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *ForStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *ForStatement) End() token.Position {
+	if s.Body != nil {
+		return s.Body.End()
+	}
+	return s.Token.End()
+}
+
+func (s *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(s.TokenLiteral() + " (")
+	if s.Variable != nil {
+		out.WriteString(s.Variable.String())
+	}
+	out.WriteString(" in ")
+	if s.Iterable != nil {
+		out.WriteString(s.Iterable.String())
+	}
+	out.WriteString(") ")
+	if s.Body != nil {
+		out.WriteString(s.Body.String())
+	}
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	*token.Token // token.BREAK トークン
+}
+
+var _ Statement = (*BreakStatement)(nil)
+
+func NewBreakStatement(token *token.Token) *BreakStatement {
+	return &BreakStatement{
+		Token: token,
+	}
+}
+
+func (s *BreakStatement) statementNode() {}
+
+func (s *BreakStatement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *BreakStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *BreakStatement) End() token.Position {
+	return s.Token.End()
+}
+
+func (s *BreakStatement) String() string {
+	return s.TokenLiteral() + ";"
+}
+
+type ContinueStatement struct {
+	*token.Token // token.CONTINUE トークン
+}
+
+var _ Statement = (*ContinueStatement)(nil)
+
+func NewContinueStatement(token *token.Token) *ContinueStatement {
+	return &ContinueStatement{
+		Token: token,
+	}
+}
+
+func (s *ContinueStatement) statementNode() {}
+
+func (s *ContinueStatement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *ContinueStatement) Pos() token.Position {
+	return s.Token.Pos()
+}
+
+func (s *ContinueStatement) End() token.Position {
+	return s.Token.End()
+}
+
+func (s *ContinueStatement) String() string {
+	return s.TokenLiteral() + ";"
+}
@@ -0,0 +1,129 @@
+package ast
+
+// Walkでノードを訪問するたびに呼ばれる
+// 戻り値のVisitorで子ノードを訪問し、nilを返すと子ノードは訪問しない
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// 構文木を深さ優先でたどる
+// ノードごとにv.Visit(node)を呼び、戻り値のVisitorで子ノードを訪問した後、
+// 最後にそのVisitorのVisit(nil)を呼ぶ
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *ForStatement:
+		if n.Variable != nil {
+			Walk(v, n.Variable)
+		}
+		walkExpression(v, n.Iterable)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *AssignExpression:
+		walkExpression(v, n.Target)
+		walkExpression(v, n.Value)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *MacroLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// 構文解析エラーで欠けた子ノードは訪問しない
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walkExpression(v, exp)
+	}
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(v, statement)
+		}
+	}
+}
+
+func walkIdentifiers(v Visitor, identifiers []*Identifier) {
+	for _, identifier := range identifiers {
+		Walk(v, identifier)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// 構文木を深さ優先でたどり、ノードごとにf(node)を呼ぶ
+// fがfalseを返すと、そのノードの子ノードは訪問しない
+// 子ノードをすべて訪問した後にf(nil)を呼ぶ
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
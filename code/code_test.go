@@ -0,0 +1,92 @@
+package code_test
+
+import (
+	"monkey/code"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	cases := []struct {
+		op       code.Opcode
+		operands []int
+		want     []byte
+	}{
+		{code.OpConstant, []int{65534}, []byte{byte(code.OpConstant), 255, 254}},
+		{code.OpAdd, []int{}, []byte{byte(code.OpAdd)}},
+		{code.OpGetLocal, []int{255}, []byte{byte(code.OpGetLocal), 255}},
+		{code.OpClosure, []int{65534, 255}, []byte{byte(code.OpClosure), 255, 254, 255}},
+	}
+
+	for _, tc := range cases {
+		instruction := code.Make(tc.op, tc.operands...)
+
+		if len(instruction) != len(tc.want) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tc.want), len(instruction))
+			continue
+		}
+
+		for i, b := range tc.want {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []code.Instructions{
+		code.Make(code.OpAdd),
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpConstant, 65535),
+		code.Make(code.OpClosure, 65535, 255),
+	}
+
+	want := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := code.Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != want {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", want, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	cases := []struct {
+		op        code.Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{code.OpConstant, []int{65535}, 2},
+		{code.OpGetLocal, []int{255}, 1},
+		{code.OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tc := range cases {
+		instruction := code.Make(tc.op, tc.operands...)
+
+		def, err := code.Lookup(byte(tc.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := code.ReadOperands(def, instruction[1:])
+		if n != tc.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tc.bytesRead, n)
+		}
+
+		for i, want := range tc.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package compiler
+
+import (
+	"monkey/ast"
+	"sort"
+)
+
+// 関数の引数と局所変数のうち、内側のクロージャが捕捉し、かつ束縛し直すものの名前を返す
+// 評価器ではクロージャが環境を共有するので、これらはセルに入れて外側の関数と共有する
+// 名前だけで判断するため、必要のない変数までセルに入れることがある
+func sharedVariables(fn *ast.FunctionLiteral) []string {
+	v := &captureVisitor{
+		captureInfo: &captureInfo{
+			bound:    map[string]bool{},
+			captured: map[string]bool{},
+			rebound:  map[string]bool{},
+			lets:     map[string]int{},
+		},
+	}
+	for _, p := range fn.Parameters {
+		v.bound[p.Value] = true
+	}
+	if fn.Body != nil {
+		ast.Walk(v, fn.Body)
+	}
+
+	names := []string{}
+	for name := range v.bound {
+		if v.captured[name] && (v.rebound[name] || v.lets[name] > 1) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type captureInfo struct {
+	bound    map[string]bool // この関数で束縛する名前
+	captured map[string]bool // 内側の関数で参照する名前
+	rebound  map[string]bool // 代入やループで束縛し直す名前
+	lets     map[string]int  // この関数でletした回数
+}
+
+type captureVisitor struct {
+	*captureInfo
+	inFunction bool // 内側の関数の中
+	inLoop     bool // この関数のループの中
+}
+
+func (v *captureVisitor) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.FunctionLiteral:
+		return &captureVisitor{captureInfo: v.captureInfo, inFunction: true}
+	case *ast.WhileStatement:
+		return &captureVisitor{captureInfo: v.captureInfo, inFunction: v.inFunction, inLoop: true}
+	case *ast.ForStatement:
+		if node.Variable != nil && !v.inFunction {
+			v.bound[node.Variable.Value] = true
+			v.rebound[node.Variable.Value] = true
+		}
+		return &captureVisitor{captureInfo: v.captureInfo, inFunction: v.inFunction, inLoop: true}
+	case *ast.LetStatement:
+		if node.Name != nil && !v.inFunction {
+			v.bound[node.Name.Value] = true
+			v.lets[node.Name.Value]++
+			if v.inLoop {
+				v.rebound[node.Name.Value] = true
+			}
+		}
+	case *ast.AssignExpression:
+		if target, ok := node.Target.(*ast.Identifier); ok {
+			v.rebound[target.Value] = true
+		}
+	case *ast.Identifier:
+		if v.inFunction {
+			v.captured[node.Value] = true
+		}
+	}
+	return v
+}
+
+// 関数リテラルの中でnameに代入しているかどうか
+func assignsTo(fn *ast.FunctionLiteral, name string) bool {
+	found := false
+	ast.Inspect(fn, func(node ast.Node) bool {
+		if assign, ok := node.(*ast.AssignExpression); ok {
+			if target, ok := assign.Target.(*ast.Identifier); ok && target.Value == name {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,697 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction // 最後に出力した命令
+	previousInstruction EmittedInstruction // lastInstructionのひとつ前に出力した命令
+	loops               []*loopContext     // コンパイル中のループ（内側のループが最後）
+}
+
+// break/continueのジャンプ先を決めるための、コンパイル中のループの情報
+type loopContext struct {
+	continuePos int   // continueで戻る位置
+	breaks      []int // ループの終わりへ飛ぶOpJumpの位置（ループの終わりが決まってから後埋めする）
+	valueDepth  int   // ループの本体を文として評価しているときのvalueDepth
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	valueDepth  int // 値として使う式をいくつ入れ子でコンパイルしているか
+}
+
+func NewCompiler() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// REPLのように複数回コンパイルする場合に、グローバル束縛と定数を引き継ぐ
+func NewCompilerWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := NewCompiler()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	// ExpressionStatementは式を埋め込んでいるので、文でないことも確かめる
+	_, isExpression := node.(ast.Expression)
+	_, isStatement := node.(ast.Statement)
+	if isExpression && !isStatement {
+		c.valueDepth++
+		defer func() { c.valueDepth-- }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		// 文として書いたifの値は使わないので、ブロックの中でbreak/continueできる
+		if exp, ok := node.Expression.(*ast.IfExpression); ok {
+			if err := c.compileIfExpression(exp); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+			return c.compileFunctionBinding(node.Name.Value, fn)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.emitSetSymbol(symbol)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop, err := c.currentLoop("break")
+		if err != nil {
+			return err
+		}
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop, err := c.currentLoop("continue")
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loop.continuePos)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "")
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		return c.compileHashLiteral(node)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.StringLiteral:
+		str := object.NewString(node.Value)
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.IntegerLiteral:
+		integer := object.NewInteger(node.Value)
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := object.NewFloat(node.Value)
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	default:
+		return fmt.Errorf("unsupported node: %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if node.Operator == "&&" || node.Operator == "||" {
+		return c.compileLogicalExpression(node)
+	}
+
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	switch node.Operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">=":
+		c.emit(code.OpGreaterThanOrEqual)
+	case "<=":
+		c.emit(code.OpLessThanOrEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+	return nil
+}
+
+// 代入した値をスタックに積む
+//
+//	x op= v: GetX; v; op; SetX; GetX
+//	a[i] op= v: a; i; v; UpdateIndex op
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var op code.Opcode
+	if node.Operator != "=" {
+		var ok bool
+		if op, ok = assignOperatorOf(node.Operator); !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("identifier not found: %s", target.Value)
+		}
+		if (symbol.Scope == FreeScope && !symbol.Cell) || symbol.Scope == FunctionScope {
+			// 代入する変数はsharedVariablesでセルに入れるので、ここには来ないはず
+			return fmt.Errorf("cannot assign to captured variable: %s", target.Value)
+		}
+
+		if node.Operator != "=" {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Operator != "=" {
+			c.emit(op)
+		}
+		c.emitSetSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Operator == "=" {
+			c.emit(code.OpSetIndex)
+		} else {
+			c.emit(code.OpUpdateIndex, int(op))
+		}
+	default:
+		return fmt.Errorf("invalid assignment target: %s", node.Target)
+	}
+	return nil
+}
+
+func assignOperatorOf(operator string) (code.Opcode, bool) {
+	switch operator {
+	case "+=":
+		return code.OpAdd, true
+	case "-=":
+		return code.OpSub, true
+	case "*=":
+		return code.OpMul, true
+	case "/=":
+		return code.OpDiv, true
+	default:
+		return 0, false
+	}
+}
+
+// 左辺だけで結果が決まる場合は右辺を評価せずに真偽値を積む
+//
+//	a && b: a; JumpNotTruthy false; b; JumpNotTruthy false; True; Jump end; false: False; end:
+//	a || b: a; Bang; JumpNotTruthy true; b; JumpNotTruthy false; true: True; Jump end; false: False; end:
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	if node.Operator == "||" {
+		c.emit(code.OpBang)
+	}
+	// ジャンプ先は後で書き換えるため、ダミーの値を入れておく
+	shortCircuitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	rightFalsePos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	truePos := c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	falsePos := c.emit(code.OpFalse)
+
+	if node.Operator == "||" {
+		c.changeOperand(shortCircuitPos, truePos)
+	} else {
+		c.changeOperand(shortCircuitPos, falsePos)
+	}
+	c.changeOperand(rightFalsePos, falsePos)
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// ジャンプ先は後で書き換えるため、ダミーの値を入れておく
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.Compile(node.Alternative); err != nil {
+			return err
+		}
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpNull)
+		}
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// start: condition; JumpNotTruthy end; body; Jump start; end:
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	startPos := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	// ジャンプ先は後で書き換えるため、ダミーの値を入れておく
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	breaks, err := c.compileLoopBody(node.Body, startPos)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperands(append(breaks, exitPos), len(c.currentInstructions()))
+	return nil
+}
+
+// 反復中の要素の一覧は、名前で参照できない隠れた束縛に置いておく
+//
+//	iterable; Iter; SetIterator; start: GetIterator; Next end; SetVariable; body; Jump start; end:
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	iterator := c.symbolTable.Define("<iterator>")
+	c.emitSetSymbol(iterator)
+
+	startPos := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+	exitPos := c.emit(code.OpNext, 9999)
+
+	// 評価器と同じく、ループ変数はループの外側のスコープに束縛する
+	variable := c.symbolTable.Define(node.Variable.Value)
+	c.emitSetSymbol(variable)
+
+	breaks, err := c.compileLoopBody(node.Body, startPos)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperands(append(breaks, exitPos), len(c.currentInstructions()))
+	return nil
+}
+
+// ループの本体をコンパイルし、後埋めが必要なbreakの位置を返す
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, continuePos int) ([]int, error) {
+	loop := &loopContext{continuePos: continuePos, valueDepth: c.valueDepth}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop)
+	defer func() { scope.loops = scope.loops[:len(scope.loops)-1] }()
+
+	if err := c.Compile(body); err != nil {
+		return nil, err
+	}
+	return loop.breaks, nil
+}
+
+// break/continueが属するループを返す
+// 評価器と同じく、ループの外や式の値として使った場合はエラーにする
+func (c *Compiler) currentLoop(keyword string) (*loopContext, error) {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil, fmt.Errorf("%s outside loop", keyword)
+	}
+	loop := loops[len(loops)-1]
+	if c.valueDepth != loop.valueDepth {
+		return nil, fmt.Errorf("%s cannot be used as a value", keyword)
+	}
+	return loop, nil
+}
+
+func (c *Compiler) compileHashLiteral(node *ast.HashLiteral) error {
+	// 評価器と同じく、ソースコードに書いた順にキーと値を評価する
+	for _, pair := range node.Pairs {
+		if err := c.Compile(pair.Key); err != nil {
+			return err
+		}
+		if err := c.Compile(pair.Value); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpHash, len(node.Pairs)*2)
+	return nil
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	// 評価器と同じく内側のクロージャと束縛を共有できるように、引数はセルに入れ直し、
+	// 局所変数には空のセルを用意しておく
+	for _, name := range sharedVariables(node) {
+		_, isParameter := c.symbolTable.store[name]
+		symbol := c.symbolTable.DefineCell(name)
+		if isParameter {
+			c.emit(code.OpGetLocal, symbol.Index)
+		} else {
+			c.emit(code.OpNull)
+		}
+		c.emit(code.OpNewCell)
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	// 最後の式の値を暗黙の戻り値にする
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.loadCapturedSymbol(s)
+	}
+
+	compiledFn := object.NewCompiledFunction(instructions, numLocals, len(node.Parameters))
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+// letで関数に名前をつける
+// 関数の中でその名前に代入する場合や、名前をセルに入れる場合は、再帰呼び出しを
+// OpCurrentClosureにせず、外側の束縛を参照する
+func (c *Compiler) compileFunctionBinding(name string, fn *ast.FunctionLiteral) error {
+	symbol := c.symbolTable.Define(name)
+	if symbol.Cell || assignsTo(fn, name) {
+		if err := c.compileFunction(fn, ""); err != nil {
+			return err
+		}
+	} else if err := c.compileFunction(fn, name); err != nil {
+		return err
+	}
+	c.emitSetSymbol(symbol)
+	return nil
+}
+
+func (c *Compiler) emitSetSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpSetFreeCell, s.Index)
+	case s.Cell:
+		c.emit(code.OpSetLocalCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpGetLocalCell, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		if s.Cell {
+			c.emit(code.OpGetFreeCell, s.Index)
+		} else {
+			c.emit(code.OpGetFree, s.Index)
+		}
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// クロージャに捕捉させる値を積む
+// セルは中身ではなくセル自体を渡して、外側の関数と共有する
+func (c *Compiler) loadCapturedSymbol(s Symbol) {
+	switch {
+	case s.Cell && s.Scope == LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Cell && s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// 命令を出力し、その開始位置を返す
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// 出力済みの命令のオペランドを書き換える（ジャンプ先の後埋めに使う）
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) changeOperands(opPositions []int, operand int) {
+	for _, pos := range opPositions {
+		c.changeOperand(pos, operand)
+	}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
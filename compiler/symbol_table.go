@@ -0,0 +1,115 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Cell  bool // 値ではなく、値を入れたセルを持つ
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	FreeSymbols    []Symbol          // 外側のスコープから捕捉した元のシンボル
+	cells          map[string]Symbol // DefineCellで確保したセル
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       map[string]Symbol{},
+		FreeSymbols: []Symbol{},
+		cells:       map[string]Symbol{},
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+func (s *SymbolTable) Define(name string) Symbol {
+	// セルを確保した名前は、何度定義しても同じセルを使う
+	if symbol, ok := s.cells[name]; ok {
+		s.store[name] = symbol
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// 局所変数をセルに入れるように定義する
+// 定義済みの引数ならその場所をセルに変え、そうでなければ場所だけを確保する
+// 確保した名前はDefineで定義するまで参照できない
+func (s *SymbolTable) DefineCell(name string) Symbol {
+	symbol, ok := s.store[name]
+	if ok && symbol.Scope == LocalScope {
+		symbol.Cell = true
+		s.store[name] = symbol
+	} else {
+		symbol = Symbol{Name: name, Index: s.numDefinitions, Scope: LocalScope, Cell: true}
+		s.numDefinitions++
+	}
+
+	s.cells[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// 関数自身の名前を定義し、再帰呼び出しで参照できるようにする
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		return s.defineFree(symbol), true
+	}
+	return symbol, ok
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope, Cell: original.Cell}
+	s.store[original.Name] = symbol
+	return symbol
+}
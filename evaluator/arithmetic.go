@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"monkey/object"
+)
+
+// 以下の関数は演算結果と、オーバーフローしなかったかどうかを返す
+// オーバーフローした場合の演算結果はGoの演算と同じ値になる
+
+func addInt64(a int64, b int64) (int64, bool) {
+	result := a + b
+	return result, (result > a) == (b > 0)
+}
+
+func subInt64(a int64, b int64) (int64, bool) {
+	result := a - b
+	return result, (result < a) == (b > 0)
+}
+
+func mulInt64(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	result := a * b
+	if result/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return result, false
+	}
+	return result, true
+}
+
+func divInt64(a int64, b int64) (int64, bool) {
+	result := a / b
+	return result, !(a == math.MinInt64 && b == -1)
+}
+
+func newOverflowError(left int64, operator string, right int64) *object.Error {
+	return object.NewError(fmt.Sprintf("integer overflow: %d %s %d", left, operator, right))
+}
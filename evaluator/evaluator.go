@@ -1,11 +1,65 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
+	"strings"
 )
 
+// コンテキストのキャンセルを確認する間隔（評価したノード数）
+const contextCheckInterval = 256
+
+// 評価器が変更することはないので、すべての評価器で共有する
+var defaultRegistry = object.NewDefaultRegistry()
+
+type Evaluator struct {
+	callStack []object.StackFrame // 実行中の関数呼び出し（外側から順に並ぶ）
+	ctx       context.Context
+
+	checkedArithmetic bool // 整数演算のオーバーフローをエラーにする
+	maxCallDepth      int  // 関数呼び出しの最大の深さ（0は無制限）
+	maxSteps          int  // 評価するノード数の上限（0は無制限）
+	steps             int  // これまでに評価したノード数
+
+	loopDepth int // 実行中の関数の中で評価しているループの深さ
+
+	registry *object.Registry    // スクリプトから参照できる組み込み関数
+	execCtx  *object.ExecContext // 組み込み関数に渡す入出力
+}
+
+func NewEvaluator(options ...Option) *Evaluator {
+	e := &Evaluator{
+		callStack: []object.StackFrame{},
+		ctx:       context.Background(),
+		registry:  defaultRegistry,
+		execCtx:   object.DefaultExecContext(),
+	}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return NewEvaluator().Eval(node, env)
+}
+
+// コンテキストがキャンセルされるかタイムアウトした時点で評価を打ち切る
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return NewEvaluator().EvalContext(ctx, node, env)
+}
+
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	e.ctx = ctx
+	defer func() { e.ctx = context.Background() }()
+
+	return e.Eval(node, env)
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -13,64 +67,127 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := e.step(); err != nil {
+		result = err
+	} else {
+		result = e.eval(node, env)
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		e.annotate(node, err)
+	}
+	return result
+}
+
+// 式の値として使うノードを評価する
+// break/continueは文としてだけ使えるので、値として受け取った場合はエラーにする
+func (e *Evaluator) evalValue(node ast.Node, env *object.Environment) object.Object {
+	result := e.Eval(node, env)
+	switch result.(type) {
+	case *object.Break:
+		return e.annotate(node, object.NewError("break cannot be used as a value"))
+	case *object.Continue:
+		return e.annotate(node, object.NewError("continue cannot be used as a value"))
+	default:
+		return result
+	}
+}
+
+// エラーを最初に受け取ったノードの位置と、その時点の呼び出し履歴を記録する
+func (e *Evaluator) annotate(node ast.Node, err *object.Error) *object.Error {
+	if err.HasPosition() {
+		return err
+	}
+	if tok := tokenOf(node); tok != nil && tok.Detail() != nil {
+		err.SetPosition(tok.Detail().LineNumber, tok.Detail().ColumnNumber)
+		err.SetStack(e.stackTrace())
+	}
+	return err
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.evalValue(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 		return nil
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.evalValue(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return object.NewReturnValue(val)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.BreakStatement:
+		if e.loopDepth == 0 {
+			return object.NewError("break outside loop")
+		}
+		return object.BREAK
+	case *ast.ContinueStatement:
+		if e.loopDepth == 0 {
+			return object.NewError("continue outside loop")
+		}
+		return object.CONTINUE
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.evalValue(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, left, env)
+		}
 
-		right := Eval(node.Right, env)
+		right := e.evalValue(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.evalInfixExpression(node.Operator, left, right)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.evalValue(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.evalPrefixExpression(node.Operator, right)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if isCallTo(node, "quote") {
+			return e.quote(node, env)
+		}
+
+		function := e.evalValue(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.applyFunction(node, function, args)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.evalValue(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := e.evalValue(node.Index, env)
 		if isError(index) {
 			return index
 		}
@@ -79,20 +196,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 		return object.NewFunction(params, body, env)
+	case *ast.MacroLiteral:
+		// マクロはDefineMacrosでトップレベルの定義だけを取り出す
+		return object.NewError("macro definition is only allowed at the top level")
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return object.NewArray(elements)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.StringLiteral:
 		return object.NewString(node.Value)
 	case *ast.IntegerLiteral:
 		return object.NewInteger(node.Value)
+	case *ast.FloatLiteral:
+		return object.NewFloat(node.Value)
 	case *ast.Boolean:
 		return object.NewBoolean(node.Value)
 	default:
@@ -100,10 +222,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	}
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -115,14 +237,14 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
-			rt := result.Type()
-			if result.Type() == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -130,16 +252,95 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(exp.Condition, env)
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	e.loopDepth++
+	defer func() { e.loopDepth-- }()
+
+	for {
+		condition := e.evalValue(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, ok := e.evalLoopBody(node.Body, env); !ok {
+			return result
+		}
+	}
+}
+
+// ループ変数はifのブロックと同じく、ループの外側の環境に束縛する
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.evalValue(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	elements, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	e.loopDepth++
+	defer func() { e.loopDepth-- }()
+
+	for _, element := range elements {
+		env.Set(node.Variable.Value, element)
+
+		if result, ok := e.evalLoopBody(node.Body, env); !ok {
+			return result
+		}
+	}
+	return nil
+}
+
+// ループの本体を評価し、ループを続けるかどうかを返す
+// ループを打ち切る場合は、ループの評価結果として返す値も返す
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := e.Eval(body, env)
+	switch result.(type) {
+	case *object.Break:
+		return nil, false
+	case *object.ReturnValue, *object.Error:
+		return result, false
+	default:
+		return nil, true
+	}
+}
+
+// for-inで順に取り出す要素を返す
+// 配列は要素、ハッシュは挿入した順のキー、文字列は1文字ずつの文字列を取り出す
+func iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(iterable.Elements))
+		copy(elements, iterable.Elements)
+		return elements, nil
+	case *object.Hash:
+		return iterable.Keys(), nil
+	case *object.String:
+		elements := []object.Object{}
+		for _, ch := range iterable.Value {
+			elements = append(elements, object.NewString(string(ch)))
+		}
+		return elements, nil
+	default:
+		return nil, object.NewError(fmt.Sprintf("cannot iterate over %s", iterable.Type()))
+	}
+}
+
+func (e *Evaluator) evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.evalValue(exp.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(exp.Consequence, env)
+		return e.Eval(exp.Consequence, env)
 	} else if exp.Alternative != nil {
-		return Eval(exp.Alternative, env)
+		return e.Eval(exp.Alternative, env)
 	} else {
 		return object.NULL
 	}
@@ -158,10 +359,29 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+// 左辺だけで結果が決まる場合は右辺を評価しない
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if node.Operator == "&&" && !isTruthy(left) {
+		return object.FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return object.TRUE
+	}
+
+	right := e.evalValue(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return object.NewBoolean(isTruthy(right))
+}
+
+func (e *Evaluator) evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// 整数と浮動小数点数の演算は浮動小数点数で行う
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -187,23 +407,51 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func (e *Evaluator) evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
 	switch operator {
 	case "+":
-		return object.NewInteger(leftVal + rightVal)
+		result, ok := addInt64(leftVal, rightVal)
+		if !ok && e.checkedArithmetic {
+			return newOverflowError(leftVal, operator, rightVal)
+		}
+		return object.NewInteger(result)
 	case "-":
-		return object.NewInteger(leftVal - rightVal)
+		result, ok := subInt64(leftVal, rightVal)
+		if !ok && e.checkedArithmetic {
+			return newOverflowError(leftVal, operator, rightVal)
+		}
+		return object.NewInteger(result)
 	case "*":
-		return object.NewInteger(leftVal * rightVal)
+		result, ok := mulInt64(leftVal, rightVal)
+		if !ok && e.checkedArithmetic {
+			return newOverflowError(leftVal, operator, rightVal)
+		}
+		return object.NewInteger(result)
 	case "/":
-		return object.NewInteger(leftVal / rightVal)
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		result, ok := divInt64(leftVal, rightVal)
+		if !ok && e.checkedArithmetic {
+			return newOverflowError(leftVal, operator, rightVal)
+		}
+		return object.NewInteger(result)
+	case "%":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		return object.NewInteger(leftVal % rightVal)
 	case "<":
 		return object.NewBoolean(leftVal < rightVal)
 	case ">":
 		return object.NewBoolean(leftVal > rightVal)
+	case "<=":
+		return object.NewBoolean(leftVal <= rightVal)
+	case ">=":
+		return object.NewBoolean(leftVal >= rightVal)
 	case "==":
 		return object.NewBoolean(leftVal == rightVal)
 	case "!=":
@@ -213,12 +461,55 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal, _ := object.FloatValue(left)
+	rightVal, _ := object.FloatValue(right)
+
+	switch operator {
+	case "+":
+		return object.NewFloat(leftVal + rightVal)
+	case "-":
+		return object.NewFloat(leftVal - rightVal)
+	case "*":
+		return object.NewFloat(leftVal * rightVal)
+	case "/":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		return object.NewFloat(leftVal / rightVal)
+	case "%":
+		if rightVal == 0 {
+			return object.NewError("division by zero")
+		}
+		return object.NewFloat(math.Mod(leftVal, rightVal))
+	case "<":
+		return object.NewBoolean(leftVal < rightVal)
+	case ">":
+		return object.NewBoolean(leftVal > rightVal)
+	case "<=":
+		return object.NewBoolean(leftVal <= rightVal)
+	case ">=":
+		return object.NewBoolean(leftVal >= rightVal)
+	case "==":
+		return object.NewBoolean(leftVal == rightVal)
+	case "!=":
+		return object.NewBoolean(leftVal != rightVal)
+	default:
+		return object.NewError(fmt.Sprintf("unknown operator: %s %s %s", left.Type(), operator, right.Type()))
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := object.FloatValue(obj)
+	return ok
+}
+
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.evalMinusPrefixOperatorExpression(right)
 	default:
 		return object.NewError(fmt.Sprintf("unknown operator: %s%s", operator, right.Type()))
 	}
@@ -242,12 +533,12 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 		return object.NewError(fmt.Sprintf("unusable as hash key: %s", index.Type()))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return object.NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
@@ -273,30 +564,122 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if float, ok := right.(*object.Float); ok {
+		return object.NewFloat(-float.Value)
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return object.NewError(fmt.Sprintf("unknown operator: -%s", right.Type()))
 	}
 	value := right.(*object.Integer).Value
-	return object.NewInteger(-value)
+	result, ok := subInt64(0, value)
+	if !ok && e.checkedArithmetic {
+		return object.NewError(fmt.Sprintf("integer overflow: -%d", value))
+	}
+	return object.NewInteger(result)
+}
+
+// 代入した値を返す
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		// 右辺を評価する前に束縛を確かめ、存在しない変数への代入で右辺の副作用が起きないようにする
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return object.NewError(fmt.Sprintf("identifier not found: %s", target.Value))
+		}
+
+		value := e.evalValue(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		value = e.evalCompoundAssignment(node.Operator, current, value)
+		if isError(value) {
+			return value
+		}
+
+		env.Assign(target.Value, value)
+		return value
+	case *ast.IndexExpression:
+		left := e.evalValue(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.evalValue(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		value := e.evalValue(node.Value, env)
+		if isError(value) {
+			return value
+		}
+		return e.evalIndexAssignment(node.Operator, left, index, value)
+	default:
+		return object.NewError(fmt.Sprintf("invalid assignment target: %s", node.Target))
+	}
+}
+
+func (e *Evaluator) evalIndexAssignment(operator string, left object.Object, index object.Object, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		array := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(array.Elements)) {
+			return object.NewError(fmt.Sprintf("index out of range: %d", idx))
+		}
+
+		value = e.evalCompoundAssignment(operator, array.Elements[idx], value)
+		if isError(value) {
+			return value
+		}
+		array.Elements[idx] = value
+		return value
+	case left.Type() == object.HASH_OBJ:
+		hash := left.(*object.Hash)
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.NewError(fmt.Sprintf("unusable as hash key: %s", index.Type()))
+		}
+
+		current, ok := hash.Get(key)
+		if !ok {
+			current = object.NULL
+		}
+		value = e.evalCompoundAssignment(operator, current, value)
+		if isError(value) {
+			return value
+		}
+		hash.Set(key, value)
+		return value
+	default:
+		return object.NewError(fmt.Sprintf("index assignment not supported: %s", left.Type()))
+	}
+}
+
+// 複合代入の場合は現在の値と演算した結果を、単純な代入の場合は右辺の値をそのまま返す
+func (e *Evaluator) evalCompoundAssignment(operator string, current object.Object, value object.Object) object.Object {
+	if operator == "=" {
+		return value
+	}
+	return e.evalInfixExpression(strings.TrimSuffix(operator, "="), current, value)
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.registry.Lookup(node.Value); ok {
 		return builtin
 	}
 
 	return object.NewError(fmt.Sprintf("identifier not found: %s", node.Value))
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, exp := range exps {
-		evaluated := Eval(exp, env)
+		evaluated := e.evalValue(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -305,14 +688,48 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// 関数オブジェクトを呼び出す
+// Goのコードからスクリプトで定義した関数を呼び出すときに使う
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(nil, fn, args)
+}
+
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	e.ctx = ctx
+	defer func() { e.ctx = context.Background() }()
+
+	return e.applyFunction(nil, fn, args)
+}
+
+func (e *Evaluator) applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return object.NewError(fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters)))
+		}
+		if e.maxCallDepth > 0 && len(e.callStack) >= e.maxCallDepth {
+			return object.NewError(fmt.Sprintf("maximum call depth exceeded: %d", e.maxCallDepth))
+		}
+		if err := e.checkContext(); err != nil {
+			return err
+		}
+
+		e.pushFrame(call)
+		defer e.popFrame()
+
+		// 関数の本体は呼び出し元のループの外側として扱う
+		loopDepth := e.loopDepth
+		e.loopDepth = 0
+		defer func() { e.loopDepth = loopDepth }()
+
 		extendEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendEnv)
+		evaluated := e.Eval(fn.Body, extendEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if err := e.checkContext(); err != nil {
+			return err
+		}
+		return fn.Call(e.execCtx, args...)
 	default:
 		return object.NewError(fmt.Sprintf("not a function: %s", fn.Type()))
 	}
@@ -333,11 +750,12 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	// キーと値はソースコードに書いた順に評価する
+	for _, pair := range node.Pairs {
+		key := e.evalValue(pair.Key, env)
 		if isError(key) {
 			return key
 		}
@@ -345,14 +763,117 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		if !ok {
 			return object.NewError(fmt.Sprintf("unusable as hash key: %s", key.Type()))
 		}
-		value := Eval(valueNode, env)
+		value := e.evalValue(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+// ノードをひとつ評価するごとに呼び出し、上限を超えていればエラーを返す
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.maxSteps > 0 && e.steps > e.maxSteps {
+		return object.NewError(fmt.Sprintf("step limit exceeded: %d", e.maxSteps))
+	}
+	if e.steps%contextCheckInterval == 0 {
+		return e.checkContext()
+	}
+	return nil
+}
+
+func (e *Evaluator) checkContext() *object.Error {
+	if err := e.ctx.Err(); err != nil {
+		return object.NewError(fmt.Sprintf("evaluation cancelled: %s", err))
+	}
+	return nil
+}
+
+func (e *Evaluator) pushFrame(call *ast.CallExpression) {
+	frame := object.StackFrame{Function: "<anonymous>"}
+	if call == nil {
+		e.callStack = append(e.callStack, frame)
+		return
+	}
+
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		frame.Function = ident.Value
+	}
+	if tok := tokenOf(call); tok != nil && tok.Detail() != nil {
+		frame.Line = tok.Detail().LineNumber
+		frame.Column = tok.Detail().ColumnNumber
+	}
+	e.callStack = append(e.callStack, frame)
+}
+
+func (e *Evaluator) popFrame() {
+	e.callStack = e.callStack[:len(e.callStack)-1]
+}
+
+// 内側の呼び出しから順に並べた呼び出し履歴を返す
+func (e *Evaluator) stackTrace() []object.StackFrame {
+	trace := make([]object.StackFrame, len(e.callStack))
+	for i, frame := range e.callStack {
+		trace[len(e.callStack)-1-i] = frame
 	}
+	return trace
+}
 
-	return object.NewHash(pairs)
+// ノードの位置を表すトークンを返す
+func tokenOf(node ast.Node) *token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.WhileStatement:
+		return node.Token
+	case *ast.ForStatement:
+		return node.Token
+	case *ast.BreakStatement:
+		return node.Token
+	case *ast.ContinueStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.AssignExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.MacroLiteral:
+		return node.Token
+	case *ast.CallExpression:
+		// '('よりも呼び出す関数の位置のほうが分かりやすい
+		return tokenOf(node.Function)
+	default:
+		return nil
+	}
 }
@@ -1,17 +1,22 @@
 package evaluator_test
 
 import (
+	"bytes"
+	"context"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -25,7 +30,7 @@ func TestStringLiteral(t *testing.T) {
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -59,11 +64,59 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 3", 1.5},
+		{"10 / 4.0", 2.5},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1.5 != 1.5", false},
+		{"1.5 / 0", "division by zero"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"float(1) / 4", 0.25},
+		{`float("2.5")`, 2.5},
+		{"int(2.9)", int64(2)},
+		{`int("42")`, int64(42)},
+		{`int("4.2")`, `could not convert "4.2" to INTEGER`},
+		{"int(true)", "argument to `int` not supported, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok {
+				t.Errorf("object is not Float. input=%q, got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if float.Value != expected {
+				t.Errorf("object has wrong value. input=%q, got=%g, want=%g", tt.input, float.Value, expected)
+			}
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -74,7 +127,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -110,11 +163,59 @@ func TestBangOperator(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"7 % 3", int64(1)},
+		{"-7 % 3", int64(-1)},
+		{"7.5 % 2", 1.5},
+		{"7 % 0", "division by zero"},
+		{"true && true", true},
+		{"true && false", false},
+		{"1 && 0", true},
+		{"false || true", true},
+		{"false || false", false},
+		{"null_value_is_not_defined || true", "identifier not found: null_value_is_not_defined"},
+		{"if (1 < 2 && 2 < 3) { 10 } else { 20 }", int64(10)},
+		// 左辺で結果が決まる場合、右辺は評価しない
+		{"false && (1 / 0)", false},
+		{"true || undefined", true},
+		{"let f = fn() { puts(1) }; false && f()", false},
+		{"true && (1 / 0)", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("object is not Float(%g). input=%q, got=%T (%+v)", expected, tt.input, evaluated, evaluated)
+			}
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -127,10 +228,15 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"if (1 < 2) { 10 } else if (1 / 0) { 20 }", 10},
+		{"let x = 3; if (x == 1) { 10 } else if (x == 2) { 20 } else if (x == 3) { 30 } else { 40 }", 30},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -140,6 +246,110 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (false) { let i = i + 1; }; i", 0},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { let sum = sum + x; }; sum", 10},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } let sum = sum + x; }; sum", 4},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } let sum = sum + x; }; sum", 3},
+		{`let s = ""; for (k in {"b": 2, "a": 1}) { let s = s + k; }; s`, "ba"},
+		{`let s = ""; for (ch in "モンキー") { let s = ch + s; }; s`, "ーキンモ"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } }; f()", 20},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } let n = n + 1; } }; n", 2},
+		// 関数の中のbreakは呼び出し元のループには影響しない
+		{"while (true) { fn() { break; }(); }", "break outside loop"},
+		{"break;", "break outside loop"},
+		{"continue;", "continue outside loop"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"while (1 + true) { }", "type mismatch: INTEGER + BOOLEAN"},
+		// break/continueは文としてだけ使え、式の値にはならない
+		{"while (true) { let x = if (true) { break; }; }", "break cannot be used as a value"},
+		{"let r = []; for (x in [1, 2, 3]) { let r = push(r, if (x == 2) { continue } else { x }); }; r", "continue cannot be used as a value"},
+		{"let f = fn() { 1 }; while (true) { f() + if (true) { break } }", "break cannot be used as a value"},
+		{"for (x in [1]) { [if (true) { continue }] }", "continue cannot be used as a value"},
+		{"for (x in [1]) { return if (true) { break } }", "break cannot be used as a value"},
+		{"let i = 0; while (true) { if (i == 2) { if (true) { break; } } i += 1; }; i", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. input=%q, got=%q, want=%q", tt.input, str.Value, expected)
+				}
+				continue
+			}
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let s = \"a\"; s += \"b\"; s", "ab"},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }(); counter(); counter(); counter()", 3},
+		{"let x = 1; let f = fn() { let x = 10; x = 20; x }; f() + x", 21},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; }; sum", 15},
+		{"let a = [1, 2, 3]; a[0] = 10; a[2] *= 3; a[0] + a[2]", 19},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`, 7},
+		{"y = 1", "identifier not found: y"},
+		{"len = 1", "identifier not found: len"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{`let h = {}; h["k"] += 1`, "type mismatch: NULL + INTEGER"},
+		{"let h = {}; h[fn() {}] = 1", "unusable as hash key: FUNCTION"},
+		{"let x = 1; x[0] = 1", "index assignment not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. input=%q, got=%q, want=%q", tt.input, str.Value, expected)
+				}
+				continue
+			}
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestAssignmentChecksTargetFirst(t *testing.T) {
+	input := "let called = [false]; let f = fn() { called[0] = true }; y = f()"
+	env := object.NewEnvironment()
+
+	evaluated := evaluator.Eval(testParseProgram(t, input), env)
+	testErrorObject(t, evaluated, "identifier not found: y")
+
+	// 代入先がないので、右辺の関数は呼ばれない
+	called, _ := env.Get("called")
+	if got := called.(*object.Array).Elements[0]; got != object.FALSE {
+		t.Errorf("right-hand side was evaluated. called[0]=%s", got.Inspect())
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -183,7 +393,7 @@ f(10);`,
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
@@ -245,25 +455,284 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"let add = fn(a, b) { a + b }; add(1);",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1, 2, 3);",
+			"wrong number of arguments. got=3, want=2",
+		},
+		{
+			"fn() { 1 }(1);",
+			"wrong number of arguments. got=1, want=0",
+		},
+		{
+			"10 / 0",
+			"division by zero",
+		},
+		{
+			"let zero = 5 - 5; 1 + 10 / zero;",
+			"division by zero",
+		},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
+
+		testErrorObject(t, evaluated, tt.expectedMessage)
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+		expectedStack  []object.StackFrame
+		expectedString string
+	}{
+		{
+			"let x = 1;\nx + true;",
+			2, 3,
+			[]object.StackFrame{},
+			"ERROR: type mismatch: INTEGER + BOOLEAN (line 2, column 3)",
+		},
+		{
+			"let add = fn(a, b) {\n  a + c\n};\nlet twice = fn(x) { add(x, x) };\ntwice(1);",
+			2, 7,
+			[]object.StackFrame{
+				{Function: "add", Line: 4, Column: 21},
+				{Function: "twice", Line: 5, Column: 1},
+			},
+			"ERROR: identifier not found: c (line 2, column 7)\n" +
+				"  in add (called at line 4, column 21)\n" +
+				"  in twice (called at line 5, column 1)",
+		},
+		{
+			"let f = fn() { len(1) };\nfn() { f() }();",
+			1, 16,
+			[]object.StackFrame{
+				{Function: "f", Line: 2, Column: 8},
+				{Function: "<anonymous>", Line: 2, Column: 1},
+			},
+			"ERROR: argument to `len` not supported, got INTEGER (line 1, column 16)\n" +
+				"  in f (called at line 2, column 8)\n" +
+				"  in <anonymous> (called at line 2, column 1)",
+		},
+		{
+			"let x = 1;\nlet y = 1.5();",
+			2, 9,
+			[]object.StackFrame{},
+			"ERROR: not a function: FLOAT (line 2, column 9)",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
 
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)",
-				evaluated, evaluated)
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
 			continue
 		}
 
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q, input=%q",
-				tt.expectedMessage, errObj.Message, tt.input)
+		if errObj.Line != tt.expectedLine || errObj.Column != tt.expectedColumn {
+			t.Errorf("wrong error position. expected=%d:%d, got=%d:%d, input=%q",
+				tt.expectedLine, tt.expectedColumn, errObj.Line, errObj.Column, tt.input)
+		}
+
+		if len(errObj.Stack) != len(tt.expectedStack) {
+			t.Errorf("wrong stack length. expected=%d, got=%d (%+v)", len(tt.expectedStack), len(errObj.Stack), errObj.Stack)
+			continue
+		}
+		for i, frame := range tt.expectedStack {
+			if errObj.Stack[i] != frame {
+				t.Errorf("wrong stack frame %d. expected=%+v, got=%+v", i, frame, errObj.Stack[i])
+			}
+		}
+
+		if errObj.Inspect() != tt.expectedString {
+			t.Errorf("wrong Inspect(). expected=%q, got=%q", tt.expectedString, errObj.Inspect())
+		}
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"let min = -9223372036854775807 - 1; min / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "integer overflow: --9223372036854775808"},
+		{"9223372036854775806 + 1", int64(9223372036854775807)},
+		{"-9223372036854775807 - 1", int64(-9223372036854775808)},
+		{"-4611686018427387904 * 2", int64(-9223372036854775808)},
+		{"3037000499 * 3037000499", int64(9223372030926249001)},
+	}
+
+	for _, tt := range tests {
+		e := evaluator.NewEvaluator(evaluator.WithCheckedArithmetic())
+		evaluated := e.Eval(testParseProgram(t, tt.input), object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// 指定しない場合はGoと同じく桁あふれする
+	testIntegerObject(t, testEval(t, "9223372036854775807 + 1"), -9223372036854775808)
+}
+
+func TestCallDepthErrorStack(t *testing.T) {
+	tests := []struct {
+		input    string
+		depth    int
+		expected string
+	}{
+		{
+			// 再帰呼び出しの繰り返しはひとつにまとめる
+			"let f = fn(x) { f(x + 1) };\nf(0);",
+			10000,
+			"ERROR: maximum call depth exceeded: 10000 (line 1, column 17)\n" +
+				"  in f (called at line 1, column 17)\n" +
+				"  ... 9998 more frames in f\n" +
+				"  in f (called at line 2, column 1)",
+		},
+		{
+			// まとめられない呼び出しは上限を超えた分を省略する
+			"let f = fn(x) { g(x) };\nlet g = fn(x) { f(x) };\nf(0);",
+			100,
+			"ERROR: maximum call depth exceeded: 100 (line 2, column 17)\n" +
+				strings.Repeat("  in g (called at line 1, column 17)\n  in f (called at line 2, column 17)\n", 10) +
+				"  ... 80 more frames",
+		},
+	}
+
+	for _, tt := range tests {
+		e := evaluator.NewEvaluator(evaluator.WithMaxCallDepth(tt.depth))
+		evaluated := e.Eval(testParseProgram(t, tt.input), object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect(). expected=%q, got=%q", tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  []evaluator.Option
+		expected interface{}
+	}{
+		{
+			"let f = fn(x) { f(x + 1) }; f(0);",
+			[]evaluator.Option{evaluator.WithMaxCallDepth(100)},
+			"maximum call depth exceeded: 100",
+		},
+		{
+			"let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(100);",
+			[]evaluator.Option{evaluator.WithMaxCallDepth(101)},
+			int64(0),
+		},
+		{
+			"let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(100);",
+			[]evaluator.Option{evaluator.WithMaxSteps(50)},
+			"step limit exceeded: 50",
+		},
+		{
+			"1 + 2",
+			[]evaluator.Option{evaluator.WithMaxSteps(5)},
+			int64(3),
+		},
+		{
+			"1 + 2",
+			[]evaluator.Option{evaluator.WithMaxSteps(4)},
+			"step limit exceeded: 4",
+		},
+		{
+			"while (true) { }",
+			[]evaluator.Option{evaluator.WithMaxSteps(1000)},
+			"step limit exceeded: 1000",
+		},
+		{
+			"for (x in [1, 2, 3]) { }",
+			[]evaluator.Option{evaluator.WithMaxSteps(5)},
+			"step limit exceeded: 5",
+		},
+	}
+
+	for _, tt := range tests {
+		e := evaluator.NewEvaluator(tt.options...)
+		evaluated := e.Eval(testParseProgram(t, tt.input), object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	fib := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(40);
+`
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		input    string
+		expected interface{}
+	}{
+		{canceled, "len([1, 2])", "evaluation cancelled: context canceled"},
+		{canceled, "fn(x) { x }(1)", "evaluation cancelled: context canceled"},
+		{timeout, fib, "evaluation cancelled: context deadline exceeded"},
+		{timeout, "while (true) { }", "evaluation cancelled: context deadline exceeded"},
+		{context.Background(), "let f = fn(x) { x * 2 }; f(21)", int64(42)},
+	}
+
+	for _, tt := range tests {
+		evaluated := evaluator.EvalContext(tt.ctx, testParseProgram(t, tt.input), object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
 		}
 	}
 }
 
+func TestExecContext(t *testing.T) {
+	var stdout bytes.Buffer
+	e := evaluator.NewEvaluator(evaluator.WithExecContext(object.NewExecContext(nil, &stdout, nil)))
+
+	evaluated := e.Eval(testParseProgram(t, `puts("hello", 1, [true])`), object.NewEnvironment())
+	testNullObject(t, evaluated)
+
+	if stdout.String() != "hello\n1\n[true]\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -273,17 +742,18 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let 値段 = 100; let 税込 = 値段 + 10; 税込;", 110},
 	}
 
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
 		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
@@ -319,7 +789,7 @@ func TestFunctionApplication(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -331,7 +801,7 @@ let newAdder = fn(x) {
 let addTwo = newAdder(2);
 addTwo(3);
 `
-	testIntegerObject(t, testEval(input), 5)
+	testIntegerObject(t, testEval(t, input), 5)
 }
 
 func TestBuiltinFunctions(t *testing.T) {
@@ -359,7 +829,7 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		switch expected := tt.expected.(type) {
 		case int:
@@ -367,16 +837,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		case nil:
 			testNullObject(t, evaluated)
 		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)",
-					evaluated, evaluated)
-				continue
-			}
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q",
-					expected, errObj.Message)
-			}
+			testErrorObject(t, evaluated, expected)
 		case []int:
 			array, ok := evaluated.(*object.Array)
 			if !ok {
@@ -400,7 +861,7 @@ func TestBuiltinFunctions(t *testing.T) {
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Array)
 	if !ok {
 		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
@@ -464,7 +925,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -485,32 +946,63 @@ func TestHashLiterals(t *testing.T) {
 		false: 6
 	}`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Hash)
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		object.TRUE.HashKey():                      5,
-		object.FALSE.HashKey():                     6,
+	// ペアはソースコードに書いた順に並ぶ
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{object.NewString("one"), 1},
+		{object.NewString("two"), 2},
+		{object.NewString("three"), 3},
+		{object.NewInteger(4), 4},
+		{object.TRUE, 5},
+		{object.FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	pairs := result.Pairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(pairs))
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for i, tt := range expected {
+		if pairs[i].Key.Inspect() != tt.key.Inspect() {
+			t.Errorf("pairs[%d] has wrong key. got=%s, want=%s", i, pairs[i].Key.Inspect(), tt.key.Inspect())
+		}
+		testIntegerObject(t, pairs[i].Value, tt.value)
+
+		value, ok := result.Get(tt.key)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
+			continue
 		}
+		testIntegerObject(t, value, tt.value)
+	}
+}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, `{b: 1, a: 2, c: 3}`},
+		{`{3: "x", 1: "y", 2: "z"}`, `{3: x, 1: y, 2: z}`},
+		{`{"a": 1, "b": 2, "a": 3}`, `{a: 3, b: 2}`},
+		{`let h = {"z": 1}; h["y"] = 2; h["z"] += 10; h["x"] = 3; h`, `{z: 11, y: 2, x: 3}`},
+		{`let keys = []; for (k in {"z": 1, "a": 2, "m": 3}) { keys = push(keys, k) }; keys`, `[z, a, m]`},
+		{`let log = []; let f = fn(x) { log = push(log, x); x }; {f(1): f(2), f(3): f(4)}; log`, `[1, 2, 3, 4]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. input=%q, got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
 	}
 }
 
@@ -550,7 +1042,7 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -560,12 +1052,183 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
-func testEval(input string) object.Object {
-	p := parser.NewParser(lexer.NewLexer(input))
-	program := p.ParseProgram()
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foobar)", "foobar"},
+		{"quote(foobar + barfoo)", "(foobar + barfoo)"},
+		{"quote(unquote(4))", "4"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"quote(unquote(4 + 4) + 8)", "(8 + 8)"},
+		{"let foobar = 8; quote(foobar)", "foobar"},
+		{"let foobar = 8; quote(unquote(foobar))", "8"},
+		{"quote(unquote(true))", "true"},
+		{"quote(unquote(true == false))", "false"},
+		{"quote(unquote(1.5 * 2))", "3.0"},
+		{`quote(unquote("a" + "b"))`, "ab"},
+		{"quote(unquote(quote(4 + 4)))", "(4 + 4)"},
+		{"let quotedInfix = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfix))", "(8 + (4 + 4))"},
+		{"quote(f(unquote(1 + 1)))", "f(2)"},
+		// 同じquoteを何度評価しても、関数本体の構文木は書き換わらない
+		{"let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)", "(2 + 1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("expected *object.Quote. input=%q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("not equal. input=%q, got=%q, want=%q", tt.input, quote.Node.String(), tt.expected)
+		}
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
 	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	evaluator.DefineMacros(program, env)
 
-	return evaluator.Eval(program, env)
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Errorf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let infixExpression = macro() { quote(1 + 2); }; infixExpression();",
+			"(1 + 2)",
+		},
+		{
+			"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);",
+			"(10 - 5) - (2 + 2)",
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+    quote(if (!(unquote(condition))) {
+        unquote(consequence);
+    } else {
+        unquote(alternative);
+    });
+};
+unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			// 関数の中のマクロ呼び出しも展開する
+			"let double = macro(x) { quote(unquote(x) * 2) }; let f = fn() { double(3) };",
+			"let f = fn() { 3 * 2 };",
+		},
+		{
+			// 同じマクロを別の引数で展開しても、前の展開結果が残らない
+			"let double = macro(x) { quote(unquote(x) * 2) }; double(1); double(2);",
+			"1 * 2; 2 * 2;",
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(t, tt.expected)
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		expanded, err := evaluator.ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected error. input=%q, got=%s", tt.input, err.Inspect())
+			continue
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. got=%q, want=%q", expanded.String(), expected.String())
+		}
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(x) { 1 }; m(2)", "macro must return a quoted node, got: INTEGER"},
+		{"let m = macro(x) { let y = x; }; m(2)", "macro must return a quoted node, got: NULL"},
+		{"let m = macro(x) { quote(unquote(x)) }; m(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"let m = macro() { quote(unquote(1 + true)) }; m()", "type mismatch: INTEGER + BOOLEAN"},
+		{"let m = macro() { quote(unquote(fn() {})) }; m()", "cannot unquote FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		_, err := evaluator.ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error. input=%q", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, err.Message)
+		}
+	}
+
+	evaluated := testEval(t, "let f = fn() { macro(x) { x } }; f()")
+	testErrorObject(t, evaluated, "macro definition is only allowed at the top level")
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	env := object.NewEnvironment()
+
+	return evaluator.Eval(testParseProgram(t, input), env)
+}
+
+// 構文解析エラーがあればテストを止める
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has %d errors. input=%q, errors=%v", len(p.Errors()), input, p.Errors())
+	}
+	return program
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -597,6 +1260,19 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Message != expected {
+		t.Errorf("wrong error message. got=%q, want=%q", result.Message, expected)
+		return false
+	}
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != object.NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
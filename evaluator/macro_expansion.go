@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// トップレベルの let name = macro(...) { ... } をマクロとして環境に登録し、
+// プログラムから取り除く
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+	for _, statement := range program.Statements {
+		if let, ok := isMacroDefinition(statement); ok {
+			addMacro(let, env)
+			continue
+		}
+		statements = append(statements, statement)
+	}
+	program.Statements = statements
+}
+
+func isMacroDefinition(node ast.Statement) (*ast.LetStatement, bool) {
+	let, ok := node.(*ast.LetStatement)
+	if !ok {
+		return nil, false
+	}
+	_, ok = let.Value.(*ast.MacroLiteral)
+	return let, ok
+}
+
+func addMacro(let *ast.LetStatement, env *object.Environment) {
+	literal := let.Value.(*ast.MacroLiteral)
+	env.Set(let.Name.Value, object.NewMacro(literal.Parameters, literal.Body, env))
+}
+
+// DefineMacrosで登録したマクロの呼び出しを、マクロが返した構文木に置き換える
+// 展開した新しい構文木を返し、引数のprogramは変更しない
+// マクロがエラーを返した場合や構文木以外を返した場合はエラーを返す
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return NewEvaluator().ExpandMacros(program, env)
+}
+
+// コンテキストがキャンセルされるかタイムアウトした時点でマクロの評価を打ち切る
+func ExpandMacrosContext(ctx context.Context, program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return NewEvaluator().ExpandMacrosContext(ctx, program, env)
+}
+
+func (e *Evaluator) ExpandMacrosContext(ctx context.Context, program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	e.ctx = ctx
+	defer func() { e.ctx = context.Background() }()
+
+	return e.ExpandMacros(program, env)
+}
+
+func (e *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	// Modifyは途中で止められないので、最初のエラーだけを覚えておく
+	var err *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, ok := lookupMacro(call, env)
+		if !ok {
+			return node
+		}
+
+		var expandedNode ast.Node
+		expandedNode, err = e.expandMacro(call, macro)
+		if err != nil {
+			return node
+		}
+		return expandedNode
+	})
+	return expanded, err
+}
+
+func lookupMacro(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func (e *Evaluator) expandMacro(call *ast.CallExpression, macro *object.Macro) (ast.Node, *object.Error) {
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, e.annotate(call, object.NewError(fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(call.Arguments), len(macro.Parameters))))
+	}
+
+	// マクロの引数は評価せずに構文木のまま渡す
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, parameter := range macro.Parameters {
+		env.Set(parameter.Value, object.NewQuote(call.Arguments[i]))
+	}
+
+	evaluated := unwrapReturnValue(e.Eval(macro.Body, env))
+	if evaluated == nil {
+		evaluated = object.NULL
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, errObj
+	}
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		return nil, e.annotate(call, object.NewError(fmt.Sprintf("macro must return a quoted node, got: %s", evaluated.Type())))
+	}
+	return quote.Node, nil
+}
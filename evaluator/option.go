@@ -0,0 +1,45 @@
+package evaluator
+
+import "monkey/object"
+
+type Option func(*Evaluator)
+
+// 整数演算のオーバーフローを検出し、エラーとして報告する
+// 指定しない場合はGoと同じく桁あふれした値になる
+func WithCheckedArithmetic() Option {
+	return func(e *Evaluator) {
+		e.checkedArithmetic = true
+	}
+}
+
+// 関数呼び出しの深さの上限を設定する
+// 際限のない再帰でGoのスタックを使い切らないようにするために使う
+func WithMaxCallDepth(depth int) Option {
+	return func(e *Evaluator) {
+		e.maxCallDepth = depth
+	}
+}
+
+// 評価するノード数の上限を設定する
+// 終わらないスクリプトを打ち切るために使う
+func WithMaxSteps(steps int) Option {
+	return func(e *Evaluator) {
+		e.maxSteps = steps
+	}
+}
+
+// スクリプトから参照できる組み込み関数を設定する
+// 指定しない場合は標準の組み込み関数だけが見える
+func WithRegistry(registry *object.Registry) Option {
+	return func(e *Evaluator) {
+		e.registry = registry
+	}
+}
+
+// 組み込み関数が使う入出力を設定する
+// 指定しない場合はプロセスの標準入出力を使う
+func WithExecContext(execCtx *object.ExecContext) Option {
+	return func(e *Evaluator) {
+		e.execCtx = execCtx
+	}
+}
@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote(...) の引数を評価せずに構文木のまま返す
+// ただし、引数の中の unquote(...) だけは評価して構文木に埋め込む
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return object.NewError(fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(call.Arguments)))
+	}
+
+	node, err := e.evalUnquoteCalls(call.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+	return object.NewQuote(node)
+}
+
+func (e *Evaluator) evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, object.Object) {
+	// Modifyは構文木全体をコピーしてから書き換えるので、quotedを含む関数やマクロの本体は
+	// 呼び出しのたびに元の形のまま残る
+	// Modifyは途中で止められないので、最初のエラーだけを覚えておく
+	var err object.Object
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if err != nil || !isCallTo(node, "unquote") {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = object.NewError(fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(call.Arguments)))
+			return node
+		}
+
+		unquoted := e.Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted
+			return node
+		}
+
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			err = object.NewError(fmt.Sprintf("cannot unquote %s", unquoted.Type()))
+			return node
+		}
+		return converted
+	})
+	return node, err
+}
+
+// 指定した名前の関数呼び出しかどうか
+func isCallTo(node ast.Node, name string) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// unquoteで評価した値を構文木に戻す
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return ast.NewIntegerLiteralByValue(obj.Value), true
+	case *object.Float:
+		return ast.NewFloatLiteral(token.NewFloatToken(obj.Inspect()), obj.Value), true
+	case *object.String:
+		return ast.NewStringLiteralByValue(obj.Value), true
+	case *object.Boolean:
+		return ast.NewBooleanByValue(obj.Inspect()), true
+	case *object.Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
@@ -2,7 +2,4 @@ module monkey
 
 go 1.15
 
-require (
-	github.com/google/go-cmp v0.5.4
-	github.com/pkg/errors v0.9.1
-)
+require github.com/google/go-cmp v0.5.4
@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"fmt"
+	"monkey/object"
+	"sort"
+)
+
+// MonkeyのオブジェクトをGoの値に変換する
+//
+//	INTEGER -> int64
+//	FLOAT   -> float64
+//	STRING  -> string
+//	BOOLEAN -> bool
+//	NULL    -> nil
+//	ARRAY   -> []interface{}
+//	HASH    -> map[interface{}]interface{}
+//
+// 関数など対応するGoの値がないものはオブジェクトのまま返す
+func ToGo(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil:
+		return nil
+	case *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = ToGo(element)
+		}
+		return elements
+	case *object.Hash:
+		pairs := make(map[interface{}]interface{}, obj.Len())
+		for _, pair := range obj.Pairs() {
+			pairs[ToGo(pair.Key)] = ToGo(pair.Value)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
+
+// Goの値をMonkeyのオブジェクトに変換する
+func FromGo(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return object.NULL, nil
+	case object.Object:
+		return value, nil
+	case int:
+		return object.NewInteger(int64(value)), nil
+	case int32:
+		return object.NewInteger(int64(value)), nil
+	case int64:
+		return object.NewInteger(value), nil
+	case float32:
+		return object.NewFloat(float64(value)), nil
+	case float64:
+		return object.NewFloat(value), nil
+	case string:
+		return object.NewString(value), nil
+	case bool:
+		return object.NewBoolean(value), nil
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, v := range value {
+			element, err := FromGo(v)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return object.NewArray(elements), nil
+	case map[string]interface{}:
+		// mapの反復順序は不定なので、キーの順に並べてから挿入する
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		hash := object.NewHash()
+		for _, k := range keys {
+			val, err := FromGo(value[k])
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(object.NewString(k), val)
+		}
+		return hash, nil
+	case map[interface{}]interface{}:
+		// ToGoがハッシュから作るmap。キーもMonkeyのオブジェクトに変換する
+		pairs := make([]object.HashPair, 0, len(value))
+		for k, v := range value {
+			key, err := FromGo(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(object.Hashable); !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			val, err := FromGo(v)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, object.HashPair{Key: key, Value: val})
+		}
+		// mapの反復順序は不定なので、キーの型と表示の順に並べてから挿入する
+		sort.Slice(pairs, func(i, j int) bool {
+			if pairs[i].Key.Type() != pairs[j].Key.Type() {
+				return pairs[i].Key.Type() < pairs[j].Key.Type()
+			}
+			return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+		})
+
+		hash := object.NewHash()
+		for _, pair := range pairs {
+			hash.Set(pair.Key.(object.Hashable), pair.Value)
+		}
+		return hash, nil
+	default:
+		return nil, fmt.Errorf("unsupported Go value: %T", value)
+	}
+}
@@ -0,0 +1,180 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+)
+
+// Goのプログラムに組み込んで使うためのインタプリタ
+// 字句解析から評価までをまとめて行い、束縛は複数回の実行で共有する
+type Interpreter struct {
+	env      *object.Environment
+	macroEnv *object.Environment // 定義したマクロ（実行時の束縛とは分けておく）
+	execCtx  *object.ExecContext
+	registry *object.Registry
+	options  []evaluator.Option
+}
+
+type Option func(*Interpreter)
+
+// スクリプトの出力先を設定する（デフォルトは標準出力）
+func WithOutput(out io.Writer) Option {
+	return func(i *Interpreter) {
+		i.execCtx.Stdout = out
+	}
+}
+
+// スクリプトのエラー出力先を設定する（デフォルトは標準エラー出力）
+func WithErrorOutput(out io.Writer) Option {
+	return func(i *Interpreter) {
+		i.execCtx.Stderr = out
+	}
+}
+
+// スクリプトの入力元を設定する（デフォルトは標準入力）
+func WithInput(in io.Reader) Option {
+	return func(i *Interpreter) {
+		i.execCtx.Stdin = in
+	}
+}
+
+// 組み込み関数を追加する
+// 同じ名前の組み込み関数がある場合は置き換える
+func WithBuiltin(builtin *object.Builtin) Option {
+	return func(i *Interpreter) {
+		i.registry.Override(builtin)
+	}
+}
+
+// 組み込み関数の集合を置き換える
+// 渡したレジストリは複製せずにそのまま使う
+func WithRegistry(registry *object.Registry) Option {
+	return func(i *Interpreter) {
+		i.registry = registry
+	}
+}
+
+func WithMaxCallDepth(depth int) Option {
+	return func(i *Interpreter) {
+		i.options = append(i.options, evaluator.WithMaxCallDepth(depth))
+	}
+}
+
+func WithMaxSteps(steps int) Option {
+	return func(i *Interpreter) {
+		i.options = append(i.options, evaluator.WithMaxSteps(steps))
+	}
+}
+
+func WithCheckedArithmetic() Option {
+	return func(i *Interpreter) {
+		i.options = append(i.options, evaluator.WithCheckedArithmetic())
+	}
+}
+
+func NewInterpreter(options ...Option) *Interpreter {
+	i := &Interpreter{
+		env:      object.NewEnvironment(),
+		macroEnv: object.NewEnvironment(),
+		execCtx:  object.DefaultExecContext(),
+		registry: object.NewDefaultRegistry(),
+	}
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+// このインタプリタから参照できる組み込み関数の集合
+// 登録した組み込み関数は次の実行から参照できる
+func (i *Interpreter) Registry() *object.Registry {
+	return i.registry
+}
+
+// ソースコードを実行し、最後に評価した値をGoの値に変換して返す
+func (i *Interpreter) Run(source string) (interface{}, error) {
+	return i.RunContext(context.Background(), source)
+}
+
+func (i *Interpreter) RunContext(ctx context.Context, source string) (interface{}, error) {
+	p := parser.NewParser(lexer.NewLexer(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, &SyntaxError{Errors: p.Errors()}
+	}
+
+	e := i.newEvaluator()
+	evaluator.DefineMacros(program, i.macroEnv)
+	expanded, err := e.ExpandMacrosContext(ctx, program, i.macroEnv)
+	if err != nil {
+		return i.result(err)
+	}
+
+	return i.result(e.EvalContext(ctx, expanded, i.env))
+}
+
+// スクリプトで定義した関数を呼び出し、戻り値をGoの値に変換して返す
+func (i *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", name)
+	}
+
+	objects := make([]object.Object, len(args))
+	for n, arg := range args {
+		obj, err := FromGo(arg)
+		if err != nil {
+			return nil, err
+		}
+		objects[n] = obj
+	}
+
+	return i.result(i.newEvaluator().ApplyContext(ctx, fn, objects...))
+}
+
+func (i *Interpreter) newEvaluator() *evaluator.Evaluator {
+	options := append([]evaluator.Option{
+		evaluator.WithRegistry(i.registry),
+		evaluator.WithExecContext(i.execCtx),
+	}, i.options...)
+	return evaluator.NewEvaluator(options...)
+}
+
+func (i *Interpreter) result(obj object.Object) (interface{}, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Object: errObj}
+	}
+	return ToGo(obj), nil
+}
+
+// 構文解析エラー
+type SyntaxError struct {
+	Errors []*parser.ParseError
+}
+
+func (e *SyntaxError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "syntax error: " + strings.Join(messages, "; ")
+}
+
+// 実行時エラー
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return strings.TrimPrefix(e.Object.Inspect(), "ERROR: ")
+}
@@ -0,0 +1,263 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"monkey/interpreter"
+	"monkey/object"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		input string
+		want  interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{"1 < 2", true},
+		{"1.5 * 2", 3.0},
+		{"if (false) { 1 }", nil},
+		{`[1, "two", [true]]`, []interface{}{int64(1), "two", []interface{}{true}}},
+		{`{"one": 1, 2: "two"}`, map[interface{}]interface{}{"one": int64(1), int64(2): "two"}},
+	}
+
+	for _, tc := range cases {
+		got, err := interpreter.NewInterpreter().Run(tc.input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.input, err)
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("failed input %q, diff (-got +want):\n%s", tc.input, diff)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	i := interpreter.NewInterpreter()
+
+	_, err := i.Run("let x = ;")
+	if _, ok := err.(*interpreter.SyntaxError); !ok {
+		t.Errorf("error is not SyntaxError. got=%T (%v)", err, err)
+	}
+
+	_, err = i.Run("1 + true")
+	runtimeErr, ok := err.(*interpreter.RuntimeError)
+	if !ok {
+		t.Fatalf("error is not RuntimeError. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Error() != "type mismatch: INTEGER + BOOLEAN (line 1, column 3)" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Error())
+	}
+}
+
+func TestMacros(t *testing.T) {
+	i := interpreter.NewInterpreter()
+
+	// マクロは次の実行でも使える
+	if _, err := i.Run("let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := i.Run(`unless(10 > 5, "not greater", "greater")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "greater" {
+		t.Errorf("wrong result. got=%v", got)
+	}
+
+	// 同じマクロを別の引数で展開しても、前の展開結果が残らない
+	got, err = i.Run(`[unless(false, "a" + "a", "x"), unless(false, "b" + "b", "y")]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(got, []interface{}{"aa", "bb"}); diff != "" {
+		t.Errorf("wrong result, diff (-got +want):\n%s", diff)
+	}
+
+	_, err = i.Run("let m = macro() { 1 }; m()")
+	if _, ok := err.(*interpreter.RuntimeError); !ok {
+		t.Errorf("error is not RuntimeError. got=%T (%v)", err, err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := interpreter.NewInterpreter()
+	if _, err := i.Run("let add = fn(a, b) { a + b }; let greet = fn(p) { \"Hello \" + p[\"name\"] };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := i.Call("add", 1, int64(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != int64(3) {
+		t.Errorf("wrong result. got=%v", got)
+	}
+
+	got, err = i.Call("greet", map[string]interface{}{"name": "Monkey"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "Hello Monkey" {
+		t.Errorf("wrong result. got=%v", got)
+	}
+
+	// Callの結果をそのまま次のCallに渡せる
+	if _, err := i.Run(`let pair = fn(k, v) { {k: v, "n": [1, 2.5]} }; let get = fn(h, k) { h[k] };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hash, err := i.Call("pair", true, "yes")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, tt := range []struct {
+		key  interface{}
+		want interface{}
+	}{
+		{true, "yes"},
+		{"n", []interface{}{int64(1), 2.5}},
+	} {
+		got, err := i.Call("get", hash, tt.key)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("wrong result for key %v (-want +got):\n%s", tt.key, diff)
+		}
+	}
+	if _, err := i.Call("get", map[interface{}]interface{}{object.NewArray(nil): 1}, 1); err == nil {
+		t.Errorf("expected error for unhashable key")
+	}
+
+	if _, err := i.Call("add", 1); err == nil {
+		t.Errorf("expected error for wrong number of arguments")
+	}
+	if _, err := i.Call("missing"); err == nil {
+		t.Errorf("expected error for missing function")
+	}
+	if _, err := i.Call("add", struct{}{}, 2); err == nil {
+		t.Errorf("expected error for unsupported argument")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var out bytes.Buffer
+	i := interpreter.NewInterpreter(
+		interpreter.WithOutput(&out),
+		interpreter.WithBuiltin(object.NewBuiltin("double", 1, "", func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return object.NewInteger(args[0].(*object.Integer).Value * 2)
+		})),
+		interpreter.WithMaxCallDepth(10),
+	)
+
+	if _, err := i.Run(`puts("hello", double(21))`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != "hello\n42\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	_, err := i.Run("let f = fn() { f() }; f();")
+	if err == nil || err.(*interpreter.RuntimeError).Object.Message != "maximum call depth exceeded: 10" {
+		t.Errorf("expected call depth error. got=%v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	fetchConfig := object.NewBuiltin("fetch_config", 1, "", func(ctx *object.ExecContext, args ...object.Object) object.Object {
+		return object.NewString("value of " + args[0].Inspect())
+	})
+
+	tenant := interpreter.NewInterpreter(interpreter.WithBuiltin(fetchConfig))
+	other := interpreter.NewInterpreter()
+
+	got, err := tenant.Run(`fetch_config("key")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "value of key" {
+		t.Errorf("wrong result. got=%v", got)
+	}
+	if _, err := other.Run(`fetch_config("key")`); err == nil {
+		t.Errorf("builtin leaked to another interpreter")
+	}
+
+	ns, err := tenant.Registry().Namespace("math")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = ns.Register(object.NewBuiltin("abs", 1, "", func(ctx *object.ExecContext, args ...object.Object) object.Object {
+		value := args[0].(*object.Integer).Value
+		if value < 0 {
+			value = -value
+		}
+		return object.NewInteger(value)
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err = tenant.Run(`math["abs"](-3)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != int64(3) {
+		t.Errorf("wrong result. got=%v", got)
+	}
+
+	_, err = tenant.Run(`math["abs"](1, 2)`)
+	if err == nil || err.(*interpreter.RuntimeError).Object.Message != "wrong number of arguments. got=2, want=1" {
+		t.Errorf("expected arity error. got=%v", err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	i := interpreter.NewInterpreter()
+	_, err := i.RunContext(ctx, "let f = fn(x) { x }; f(1);")
+	if err == nil {
+		t.Fatalf("expected cancellation error")
+	}
+
+	_, err = i.CallContext(ctx, "f", 1)
+	if err == nil {
+		t.Fatalf("expected cancellation error")
+	}
+}
+
+func TestRunContextMacroExpansion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// マクロの展開中も、コンテキストのタイムアウトで打ち切る
+	i := interpreter.NewInterpreter()
+	done := make(chan error, 1)
+	go func() {
+		_, err := i.RunContext(ctx, "let m = macro() { while (true) { } quote(1) }; m()")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if _, ok := err.(*interpreter.RuntimeError); !ok {
+			t.Errorf("error is not RuntimeError. got=%T (%v)", err, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("macro expansion was not cancelled")
+	}
+}
+
+func ExampleInterpreter() {
+	i := interpreter.NewInterpreter()
+	i.Run(`let greet = fn(name) { "Hello, " + name + "!" };`)
+
+	result, _ := i.Call("greet", "Monkey")
+	fmt.Println(result)
+	// Output: Hello, Monkey!
+}
@@ -1,5 +1,7 @@
 package lexer
 
+import "unicode/utf8"
+
 type DebugTracer struct {
 	Line       string
 	LineNumber int
@@ -11,16 +13,19 @@ func newDebugTracer() *DebugTracer {
 	return d
 }
 
+// 次に読む文字の列番号（1始まり）
+// 列番号はバイト数ではなく文字数で数える
 func (d *DebugTracer) columnNumber() int {
-	return len(d.Line)
+	return utf8.RuneCountInString(d.Line) + 1
 }
 
 func (d *DebugTracer) incrementLine() {
 	d.LineNumber += 1
 }
 
-func (d *DebugTracer) appendChar(ch byte) {
-	if ch != '\n' {
+func (d *DebugTracer) appendChar(ch rune) {
+	// 読み込み開始前のNUL文字と改行は行に含めない
+	if ch != 0 && ch != '\n' {
 		d.Line += string(ch)
 	}
 }
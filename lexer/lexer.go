@@ -1,22 +1,42 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	input        string       // 字句解析対象の入力文字列
 	position     int          // 入力における現在の位置／現在の文字を指し示す
 	readPosition int          // これから読み込む位置／現在の文字の次
-	ch           byte         // 現在検査中の文字
+	ch           rune         // 現在検査中の文字
 	debugTracer  *DebugTracer // デバッグ詳細情報のトレーサー
+
+	emitComments bool // コメントを読み飛ばさずにCOMMENTトークンとして返す
+}
+
+type Option func(*Lexer)
+
+// コメントをCOMMENTトークンとして返す
+// フォーマッタなどコメントを残す必要があるツールで使う
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
 }
 
-func NewLexer(input string) *Lexer {
+func NewLexer(input string, options ...Option) *Lexer {
 	l := &Lexer{
 		input:       input,
 		debugTracer: newDebugTracer(),
 	}
+	for _, option := range options {
+		option(l)
+	}
 	l.readChar()
 	return l
 }
@@ -25,41 +45,86 @@ func (l *Lexer) NextToken() *token.Token {
 	// 空白改行は読み飛ばす
 	l.skipWhitespace()
 
+	// トークンの開始位置を記録しておく
+	detail := l.detail()
+
 	var tok *token.Token
 
 	switch l.ch {
 	case '"':
-		tok = token.NewStringToken(l.readString())
+		tok = l.readString()
 	case '=':
 		if l.peekChar() == '=' {
-			first := l.ch // 1文字目を保存
-			l.readChar()  // 2文字目を読む
-			literal := string(first) + string(l.ch)
-			tok = token.NewToken(token.EQ, literal)
+			tok = l.readTwoCharToken(token.EQ)
 		} else {
 			tok = token.NewTokenByChar(token.ASSIGN, l.ch)
 		}
 	case '!':
 		if l.peekChar() == '=' {
-			first := l.ch // 1文字目を保存
-			l.readChar()  // 2文字目を読む
-			literal := string(first) + string(l.ch)
-			tok = token.NewToken(token.NOT_EQ, literal)
+			tok = l.readTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = token.NewTokenByChar(token.BANG, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = token.NewIllegalToken(string(l.ch), "unexpected '&', did you mean '&&'?")
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = token.NewIllegalToken(string(l.ch), "unexpected '|', did you mean '||'?")
+		}
+	case '%':
+		tok = token.NewTokenByChar(token.PERCENT, l.ch)
 	case '+':
-		tok = token.NewTokenByChar(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = token.NewTokenByChar(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = token.NewTokenByChar(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = token.NewTokenByChar(token.MINUS, l.ch)
+		}
 	case '*':
-		tok = token.NewTokenByChar(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = token.NewTokenByChar(token.ASTERISK, l.ch)
+		}
 	case '/':
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.SLASH_ASSIGN)
+			break
+		}
+		if l.peekChar() == '/' || l.peekChar() == '*' {
+			// コメントはreadCommentメソッド内で読み終わっているので、それ以上読む必要はない
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && !l.emitComments {
+				return l.NextToken()
+			}
+			tok.SetDetail(detail)
+			detail.SetEnd(l.currentPosition())
+			return tok
+		}
 		tok = token.NewTokenByChar(token.SLASH, l.ch)
 	case '<':
-		tok = token.NewTokenByChar(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.LT_EQ)
+		} else {
+			tok = token.NewTokenByChar(token.LT, l.ch)
+		}
 	case '>':
-		tok = token.NewTokenByChar(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.GT_EQ)
+		} else {
+			tok = token.NewTokenByChar(token.GT, l.ch)
+		}
 	case '(':
 		tok = token.NewTokenByChar(token.LPAREN, l.ch)
 	case ')':
@@ -83,31 +148,145 @@ func (l *Lexer) NextToken() *token.Token {
 	default:
 		if l.isLetter() {
 			// 識別子はreadIdentifierメソッド内で読み終わっているので、それ以上読む必要はない
-			return l.readIdentifier()
+			tok = l.readIdentifier()
+			tok.SetDetail(detail)
+			detail.SetEnd(l.currentPosition())
+			return tok
 		} else if l.isDigit() {
 			// 数字はreadNumberメソッド内で読み終わっているので、それ以上読む必要はない
-			return l.readNumber()
+			tok = l.readNumber()
+			tok.SetDetail(detail)
+			detail.SetEnd(l.currentPosition())
+			return tok
 		}
 		tok = token.NewTokenByChar(token.ILLEGAL, l.ch)
 	}
 
 	// デバッグ用に詳細情報をトークンに追加
-	tok.SetDetail(l.detail())
+	tok.SetDetail(detail)
 
 	l.readChar()
+	detail.SetEnd(l.currentPosition())
 	return tok
 }
 
-// 文字列を読み進める
-func (l *Lexer) readString() string {
-	beginPosition := l.position + 1
+// 2文字の演算子を読み進める
+// 読み終わった時点では2文字目を指している
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) *token.Token {
+	first := l.ch // 1文字目を保存
+	l.readChar()  // 2文字目を読む
+	return token.NewToken(tokenType, string(first)+string(l.ch))
+}
+
+// 文字列を読み進め、エスケープシーケンスを展開する
+// 閉じる '"' がない場合や不正なエスケープシーケンスがある場合はILLEGALトークンを返す
+func (l *Lexer) readString() *token.Token {
+	beginPosition := l.position
+	var value strings.Builder
+	var message string
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '"':
+			if message != "" {
+				return token.NewIllegalToken(l.input[beginPosition:l.position+1], message)
+			}
+			return token.NewStringToken(value.String())
+		case 0:
+			return token.NewIllegalToken(l.input[beginPosition:l.position], "unterminated string")
+		case '\\':
+			l.readChar()
+			if l.ch == 0 {
+				return token.NewIllegalToken(l.input[beginPosition:l.position], "unterminated string")
+			}
+			// 最初のエラーだけを報告し、閉じる '"' までは読み進める
+			if err := l.readEscape(&value); err != "" && message == "" {
+				message = err
+			}
+		default:
+			value.WriteRune(l.ch)
+		}
+	}
+}
+
+// '\' の次の文字から始まるエスケープシーケンスを読み、展開した文字を追加する
+// 不正なエスケープシーケンスの場合はエラーメッセージを返す
+func (l *Lexer) readEscape(value *strings.Builder) string {
+	switch l.ch {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '"':
+		value.WriteByte('"')
+	case '\\':
+		value.WriteByte('\\')
+	case 'u':
+		return l.readUnicodeEscape(value)
+	default:
+		return fmt.Sprintf("unknown escape sequence: \\%c", l.ch)
+	}
+	return ""
+}
+
+// \u{1F600} の形式のエスケープシーケンスを読む
+func (l *Lexer) readUnicodeEscape(value *strings.Builder) string {
+	if l.peekChar() != '{' {
+		return "invalid unicode escape: expected '{' after \\u"
+	}
+	l.readChar()
+
+	beginPosition := l.position + 1
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[beginPosition : l.position+1]
+
+	if l.peekChar() != '}' {
+		return "invalid unicode escape: expected '}'"
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		return fmt.Sprintf("invalid unicode code point: %q", digits)
+	}
+	value.WriteRune(rune(code))
+	return ""
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// コメントを読み進める
+// 行コメントは改行の手前まで、ブロックコメントは閉じる "*/" までを読む
+func (l *Lexer) readComment() *token.Token {
+	beginPosition := l.position
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return token.NewToken(token.COMMENT, strings.TrimRight(l.input[beginPosition:l.position], "\r"))
+	}
+
+	// 開始の "/*" を読み飛ばす
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			return token.NewIllegalToken(l.input[beginPosition:l.position], "unterminated block comment")
 		}
+		l.readChar()
 	}
-	return l.input[beginPosition:l.position]
+	// 終了の "*/" を読み飛ばす
+	l.readChar()
+	l.readChar()
+	return token.NewToken(token.COMMENT, l.input[beginPosition:l.position])
 }
 
 // 識別子を読み進める
@@ -118,70 +297,102 @@ func (l *Lexer) readIdentifier() *token.Token {
 	}
 	literal := l.input[beginPosition:l.position]
 
-	tok := token.NewIdentifierToken(literal)
-	tok.SetDetail(l.detail())
-	return tok
+	return token.NewIdentifierToken(literal)
 }
 
 // 使用可能な文字かチェックする
+// ASCII以外の文字もUnicodeの文字であれば識別子に使える
 func (l *Lexer) isLetter() bool {
-	return 'a' <= l.ch && l.ch <= 'z' || 'A' <= l.ch && l.ch <= 'Z' || l.ch == '_'
+	return unicode.IsLetter(l.ch) || l.ch == '_'
 }
 
 // 数字を読み進める
+// 小数点の後に数字が続く場合は浮動小数点数として読む
 func (l *Lexer) readNumber() *token.Token {
 	beginPosition := l.position
 	for l.isDigit() {
 		l.readChar()
 	}
-	literal := l.input[beginPosition:l.position]
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return token.NewIntegerToken(l.input[beginPosition:l.position])
+	}
 
-	tok := token.NewIntegerToken(literal)
-	tok.SetDetail(l.detail())
-	return tok
+	l.readChar()
+	for l.isDigit() {
+		l.readChar()
+	}
+	return token.NewFloatToken(l.input[beginPosition:l.position])
 }
 
 // 数字かチェックする
 func (l *Lexer) isDigit() bool {
-	return '0' <= l.ch && l.ch <= '9'
+	return isDigit(l.ch)
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
 }
 
 // 次の一文字を読んで、位置ポインタを更新する
-// positionは常にreadPositionの次を指し示す
+// 位置ポインタはバイト単位で、UTF-8の文字はまとめて1文字として読む
 // 終端までいったらASCIIコードのNUL文字をセットする
 func (l *Lexer) readChar() {
 	// デバッグ用
-	l.debugTracer.appendChar(l.ch)
+	// 文字列やコメントの途中の改行も行番号に反映する
+	if l.ch == '\n' {
+		l.debugTracer.incrementLine()
+		l.debugTracer.resetLine()
+	} else {
+		l.debugTracer.appendChar(l.ch)
+	}
 
-	l.ch = l.peekChar()
+	ch, width := l.decodeChar(l.readPosition)
+	l.ch = ch
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
 // 次の一文字を覗き見（peek）する
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0 // NUL文字
-	} else {
-		return l.input[l.readPosition]
+func (l *Lexer) peekChar() rune {
+	ch, _ := l.decodeChar(l.readPosition)
+	return ch
+}
+
+// 指定した位置の文字とそのバイト数を返す
+func (l *Lexer) decodeChar(position int) (rune, int) {
+	if position >= len(l.input) {
+		return 0, 1 // NUL文字
 	}
+	return utf8.DecodeRuneInString(l.input[position:])
 }
 
 // 空白改行を無視する
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\n' {
-		// デバッグ用
-		if l.ch == '\n' {
-			l.debugTracer.incrementLine()
-			l.debugTracer.resetLine()
-		}
-
 		l.readChar()
 	}
 }
 
 func (l *Lexer) detail() *token.DetailToken {
-	return token.NewDetailToken(l.debugTracer.Line, l.debugTracer.LineNumber, l.debugTracer.columnNumber())
+	return token.NewDetailToken(l.debugTracer.Line, l.debugTracer.LineNumber, l.debugTracer.columnNumber(), l.offset())
+}
+
+// 現在の文字の位置
+// トークンを読み終えた直後に呼ぶと、そのトークンの終了位置（直後の位置）になる
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Offset: l.offset(),
+		Line:   l.debugTracer.LineNumber,
+		Column: l.debugTracer.columnNumber(),
+	}
+}
+
+// 終端を越えて読み進めても入力の長さを超えないようにする
+func (l *Lexer) offset() int {
+	if l.position > len(l.input) {
+		return len(l.input)
+	}
+	return l.position
 }
 
 func (l *Lexer) Input() string {
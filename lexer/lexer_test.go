@@ -16,7 +16,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -31,6 +31,10 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo":"bar"}
+3.14 * 2.0;
+1.foo
+a <= b >= c && d || e % f;
+a = 1; a += 1; a -= 1; a *= 2; a /= 2;
 `
 
 	tests := []struct {
@@ -123,6 +127,45 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.FLOAT, "3.14"},
+		{token.ASTERISK, "*"},
+		{token.FLOAT, "2.0"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "foo"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -135,7 +178,7 @@ if (5 < 10) {
 		//	t.Fatalf("tests[%d] - error: %+v", i, err)
 		//}
 
-		if tok.Type == token.ILLEGAL {
+		if tok.Type == token.ILLEGAL && tt.expectedType != token.ILLEGAL {
 			t.Fatalf("tests[%d] - illegal token: '%s', detail=%s", i, tok.Literal, tok.Detail())
 		}
 
@@ -150,3 +193,185 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestLexerString(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedMessage string
+	}{
+		{`"plain"`, token.STRING, "plain", ""},
+		{`"a\nb\tc\rd"`, token.STRING, "a\nb\tc\rd", ""},
+		{`"say \"hi\""`, token.STRING, `say "hi"`, ""},
+		{`"back\\slash"`, token.STRING, `back\slash`, ""},
+		{`"\u{41}\u{3042}\u{1F600}"`, token.STRING, "Aあ😀", ""},
+		{`"multi
+line"`, token.STRING, "multi\nline", ""},
+		{`"abc`, token.ILLEGAL, `"abc`, "unterminated string"},
+		{`"abc\`, token.ILLEGAL, `"abc\`, "unterminated string"},
+		{`"a\qb"`, token.ILLEGAL, `"a\qb"`, "unknown escape sequence: \\q"},
+		{`"\u41"`, token.ILLEGAL, `"\u41"`, "invalid unicode escape: expected '{' after \\u"},
+		{`"\u{41"`, token.ILLEGAL, `"\u{41"`, "invalid unicode escape: expected '}'"},
+		{`"\u{}"`, token.ILLEGAL, `"\u{}"`, `invalid unicode code point: ""`},
+		{`"\u{D800}"`, token.ILLEGAL, `"\u{D800}"`, `invalid unicode code point: "D800"`},
+		{`"\u{110000}"`, token.ILLEGAL, `"\u{110000}"`, `invalid unicode code point: "110000"`},
+	}
+
+	for _, tt := range tests {
+		tok := lexer.NewLexer(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("input %q - TokenType wrong. expected=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("input %q - Literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Message() != tt.expectedMessage {
+			t.Errorf("input %q - Message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, tok.Message())
+		}
+	}
+}
+
+func TestLexerComments(t *testing.T) {
+	input := `// header
+let x = 1; // trailing
+/* block
+   comment */ x / 2;
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "1", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		{token.IDENT, "x", 4, 15},
+		{token.SLASH, "/", 4, 17},
+		{token.INT, "2", 4, 19},
+		{token.SEMICOLON, ";", 4, 20},
+		{token.EOF, "", 5, 1},
+	}
+
+	l := lexer.NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s(%q), got=%s(%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Detail().LineNumber != tt.expectedLine || tok.Detail().ColumnNumber != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Detail().LineNumber, tok.Detail().ColumnNumber)
+		}
+	}
+
+	expected := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// header"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block\n   comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l = lexer.NewLexer(input, lexer.WithComments())
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("expected[%d] - token wrong. expected=%s(%q), got=%s(%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	tok := lexer.NewLexer("/* open").NextToken()
+	if tok.Type != token.ILLEGAL || tok.Message() != "unterminated block comment" {
+		t.Errorf("expected unterminated block comment. got=%s(%q) %q", tok.Type, tok.Literal, tok.Message())
+	}
+}
+
+func TestLexerUnicode(t *testing.T) {
+	input := `let 名前 = "モンキー";
+名前 + "さん" + café;
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "名前", 1, 5},
+		{token.ASSIGN, "=", 1, 8},
+		{token.STRING, "モンキー", 1, 10},
+		{token.SEMICOLON, ";", 1, 16},
+		{token.IDENT, "名前", 2, 1},
+		{token.PLUS, "+", 2, 4},
+		{token.STRING, "さん", 2, 6},
+		{token.PLUS, "+", 2, 11},
+		{token.IDENT, "café", 2, 13},
+		{token.SEMICOLON, ";", 2, 17},
+		{token.EOF, "", 3, 1},
+	}
+
+	l := lexer.NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s(%q), got=%s(%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Detail().LineNumber != tt.expectedLine || tok.Detail().ColumnNumber != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Detail().LineNumber, tok.Detail().ColumnNumber)
+		}
+	}
+}
+
+func TestLexerPositions(t *testing.T) {
+	input := "let s = \"a\nb\";\n名前 /* c */ == 1"
+
+	tests := []struct {
+		literal string
+		pos     token.Position
+		end     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"s", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"a\nb", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 13, Line: 2, Column: 3}},
+		{";", token.Position{Offset: 13, Line: 2, Column: 3}, token.Position{Offset: 14, Line: 2, Column: 4}},
+		{"名前", token.Position{Offset: 15, Line: 3, Column: 1}, token.Position{Offset: 21, Line: 3, Column: 3}},
+		{"==", token.Position{Offset: 30, Line: 3, Column: 12}, token.Position{Offset: 32, Line: 3, Column: 14}},
+		{"1", token.Position{Offset: 33, Line: 3, Column: 15}, token.Position{Offset: 34, Line: 3, Column: 16}},
+		{"", token.Position{Offset: 34, Line: 3, Column: 16}, token.Position{Offset: 34, Line: 3, Column: 16}},
+	}
+
+	l := lexer.NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.literal, tok.Literal)
+		}
+		if tok.Pos() != tt.pos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.pos, tok.Pos())
+		}
+		if tok.End() != tt.end {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.end, tok.End())
+		}
+	}
+}
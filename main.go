@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
@@ -12,7 +15,69 @@ import (
 )
 
 func main() {
-	runRepl()
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  file: path to a .monkey script, or \"-\" to read from stdin\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  (starts the REPL when omitted)\n")
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		runRepl()
+		return
+	}
+	os.Exit(runFile(flag.Arg(0)))
+}
+
+// スクリプトファイルを実行し、終了ステータスを返す
+func runFile(path string) int {
+	name, input, err := readSource(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, err := range p.Errors() {
+			printParseError(os.Stderr, name, err)
+		}
+		return 1
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+	if errObj != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, errObj.Inspect())
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	evaluated := evaluator.Eval(expanded, env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, errObj.Inspect())
+		return 1
+	}
+	return 0
+}
+
+func readSource(path string) (string, string, error) {
+	if path == "-" {
+		input, err := ioutil.ReadAll(os.Stdin)
+		return "<stdin>", string(input), err
+	}
+
+	input, err := ioutil.ReadFile(path)
+	return path, string(input), err
+}
+
+func printParseError(out io.Writer, name string, err *parser.ParseError) {
+	fmt.Fprintf(out, "%s:%s\n", name, err.Error())
+	if caret := err.Caret(); caret != "" {
+		fmt.Fprintf(out, "%s\n", caret)
+	}
 }
 
 func runRepl() {
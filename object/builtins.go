@@ -0,0 +1,110 @@
+package object
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// 組み込み関数の一覧
+// コンパイラはこの並び順をインデックスとして使うため、要素の追加は末尾に行う
+var Builtins = []*Builtin{
+	NewBuiltin("len", 1, "Returns the number of elements in an array or bytes in a string.", func(ctx *ExecContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Array:
+			return NewInteger(int64(len(arg.Elements)))
+		case *String:
+			return NewInteger(int64(len(arg.Value)))
+		default:
+			return NewError(fmt.Sprintf("argument to `len` not supported, got %s", args[0].Type()))
+		}
+	}),
+	NewBuiltin("puts", VariadicArity, "Prints each argument on its own line to stdout.", func(ctx *ExecContext, args ...Object) Object {
+		for _, arg := range args {
+			fmt.Fprintln(ctx.Stdout, arg.Inspect())
+		}
+		return NULL
+	}),
+	NewBuiltin("first", 1, "Returns the first element of an array.", func(ctx *ExecContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return NewError(fmt.Sprintf("argument to `first` must be ARRAY, got %s", args[0].Type()))
+		}
+
+		array := args[0].(*Array)
+		if len(array.Elements) > 0 {
+			return array.Elements[0]
+		}
+		return NULL
+	}),
+	NewBuiltin("last", 1, "Returns the last element of an array.", func(ctx *ExecContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return NewError(fmt.Sprintf("argument to `last` must be ARRAY, got %s", args[0].Type()))
+		}
+
+		array := args[0].(*Array)
+		length := len(array.Elements)
+		if length > 0 {
+			return array.Elements[length-1]
+		}
+		return NULL
+	}),
+	NewBuiltin("rest", 1, "Returns a new array without the first element.", func(ctx *ExecContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return NewError(fmt.Sprintf("argument to `last` must be ARRAY, got %s", args[0].Type()))
+		}
+
+		array := args[0].(*Array)
+		length := len(array.Elements)
+		if length > 0 {
+			newElements := make([]Object, length-1, length-1)
+			copy(newElements, array.Elements[1:length])
+			return NewArray(newElements)
+		}
+		return NULL
+	}),
+	NewBuiltin("push", 2, "Returns a new array with an element appended.", func(ctx *ExecContext, args ...Object) Object {
+		if args[0].Type() != ARRAY_OBJ {
+			return NewError(fmt.Sprintf("argument to `push` must be ARRAY, got %s", args[0].Type()))
+		}
+
+		array := args[0].(*Array)
+		length := len(array.Elements)
+
+		newElements := make([]Object, length+1, length+1)
+		copy(newElements, array.Elements)
+		newElements[length] = args[1]
+
+		return NewArray(newElements)
+	}),
+	NewBuiltin("int", 1, "Converts a number or numeric string to an integer, truncating any fraction.", func(ctx *ExecContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Integer:
+			return arg
+		case *Float:
+			return NewInteger(int64(arg.Value))
+		case *String:
+			value, err := strconv.ParseInt(arg.Value, 0, 64)
+			if err != nil {
+				return NewError(fmt.Sprintf("could not convert %q to INTEGER", arg.Value))
+			}
+			return NewInteger(value)
+		default:
+			return NewError(fmt.Sprintf("argument to `int` not supported, got %s", args[0].Type()))
+		}
+	}),
+	NewBuiltin("float", 1, "Converts a number or numeric string to a float.", func(ctx *ExecContext, args ...Object) Object {
+		switch arg := args[0].(type) {
+		case *Integer:
+			return NewFloat(float64(arg.Value))
+		case *Float:
+			return arg
+		case *String:
+			value, err := strconv.ParseFloat(arg.Value, 64)
+			if err != nil {
+				return NewError(fmt.Sprintf("could not convert %q to FLOAT", arg.Value))
+			}
+			return NewFloat(value)
+		default:
+			return NewError(fmt.Sprintf("argument to `float` not supported, got %s", args[0].Type()))
+		}
+	}),
+}
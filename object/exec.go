@@ -0,0 +1,23 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+// 組み込み関数に渡す実行環境
+// 入出力を差し替えることで、スクリプトの出力をテストで取得したりクライアントに送ったりできる
+type ExecContext struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func NewExecContext(stdin io.Reader, stdout io.Writer, stderr io.Writer) *ExecContext {
+	return &ExecContext{Stdin: stdin, Stdout: stdout, Stderr: stderr}
+}
+
+// プロセスの標準入出力を使う実行環境
+func DefaultExecContext() *ExecContext {
+	return NewExecContext(os.Stdin, os.Stdout, os.Stderr)
+}
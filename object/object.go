@@ -5,23 +5,32 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"monkey/code"
+	"strconv"
 	"strings"
 )
 
 type ObjectType string
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(ctx *ExecContext, args ...Object) Object
 
 const (
 	STRING_OBJ       = "STRING"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
 	ERROR_OBJ        = "ERROR"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -65,6 +74,41 @@ func (i Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+type Float struct {
+	Value float64
+}
+
+func NewFloat(value float64) *Float {
+	return &Float{Value: value}
+}
+
+var _ Object = (*Float)(nil)
+
+func (f Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// 整数と区別できるように、小数部がなくても小数点を付ける
+func (f Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
+// 整数または浮動小数点数の値をfloat64として返す
+func FloatValue(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -123,6 +167,37 @@ func (r ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+// breakによってループを抜けることを表す
+// ReturnValueと同じく、ループに届くまでブロックの評価を打ち切るために使う
+type Break struct{}
+
+var BREAK = &Break{}
+
+var _ Object = (*Break)(nil)
+
+func (b Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b Break) Inspect() string {
+	return "break"
+}
+
+// continueによってループの次の繰り返しに進むことを表す
+type Continue struct{}
+
+var CONTINUE = &Continue{}
+
+var _ Object = (*Continue)(nil)
+
+func (c Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c Continue) Inspect() string {
+	return "continue"
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -191,12 +266,130 @@ func (f Function) Inspect() string {
 	return out.String()
 }
 
+// quoteで評価せずに残した構文木
+type Quote struct {
+	Node ast.Node
+}
+
+func NewQuote(node ast.Node) *Quote {
+	return &Quote{Node: node}
+}
+
+var _ Object = (*Quote)(nil)
+
+func (q Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+func (q Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// 引数を評価せずに構文木のまま受け取り、構文木を返す関数
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func NewMacro(parameters []*ast.Identifier, body *ast.BlockStatement, env *Environment) *Macro {
+	return &Macro{
+		Parameters: parameters,
+		Body:       body,
+		Env:        env,
+	}
+}
+
+var _ Object = (*Macro)(nil)
+
+func (m Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+func (m Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, parameter := range m.Parameters {
+		params = append(params, parameter.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // ローカル束縛の数
+	NumParameters int
+}
+
+func NewCompiledFunction(instructions code.Instructions, numLocals int, numParameters int) *CompiledFunction {
+	return &CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: numParameters,
+	}
+}
+
+var _ Object = (*CompiledFunction)(nil)
+
+func (f *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (f *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", f)
+}
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object // 捕捉した自由変数
+}
+
+func NewClosure(fn *CompiledFunction, free []Object) *Closure {
+	return &Closure{
+		Fn:   fn,
+		Free: free,
+	}
+}
+
+var _ Object = (*Closure)(nil)
+
+// スクリプトから見ると評価器のFunctionと同じ関数として扱う
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// 可変長引数の組み込み関数のArity
+const VariadicArity = -1
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Name  string
+	Arity int    // 引数の数（VariadicArityの場合は確認しない）
+	Doc   string // 関数の説明
+	Fn    BuiltinFunction
 }
 
-func NewBuiltin(fn BuiltinFunction) *Builtin {
-	return &Builtin{Fn: fn}
+func NewBuiltin(name string, arity int, doc string, fn BuiltinFunction) *Builtin {
+	return &Builtin{Name: name, Arity: arity, Doc: doc, Fn: fn}
+}
+
+// 引数の数を確認してから組み込み関数を呼び出す
+func (b *Builtin) Call(ctx *ExecContext, args ...Object) Object {
+	if b.Arity != VariadicArity && len(args) != b.Arity {
+		return NewError(fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), b.Arity))
+	}
+	return b.Fn(ctx, args...)
 }
 
 var _ Object = (*Builtin)(nil)
@@ -211,20 +404,94 @@ func (b Builtin) Inspect() string {
 
 type Error struct {
 	Message string
+	Line    int          // エラーが起きた位置（1始まり、不明な場合は0）
+	Column  int          // エラーが起きた位置（1始まり、不明な場合は0）
+	Stack   []StackFrame // エラーが起きた時点の呼び出し履歴（内側の呼び出しから順に並ぶ）
+	Omitted int          // 上限を超えてStackに残さなかった呼び出しの数
 }
 
+// Errorに残す呼び出し履歴の上限（続けて繰り返した同じ呼び出しはひとつと数える）
+const maxStackFrames = 20
+
 var _ Object = (*Error)(nil)
 
 func NewError(message string) *Error {
 	return &Error{Message: message}
 }
 
+func (e *Error) HasPosition() bool {
+	return e.Line > 0
+}
+
+func (e *Error) SetPosition(line int, column int) {
+	e.Line = line
+	e.Column = column
+}
+
+// 深い再帰でエラーが大きくなりすぎないよう、同じ位置からの呼び出しが続く部分はひとつにまとめ、
+// 上限を超えた分は数だけを残す
+func (e *Error) SetStack(stack []StackFrame) {
+	e.Stack = []StackFrame{}
+	e.Omitted = 0
+	for i, frame := range stack {
+		if n := len(e.Stack); n > 0 && e.Stack[n-1].sameCall(frame) {
+			e.Stack[n-1].Repeated += 1 + frame.Repeated
+			continue
+		}
+		if len(e.Stack) == maxStackFrames {
+			e.Omitted = len(stack) - i
+			break
+		}
+		e.Stack = append(e.Stack, frame)
+	}
+}
+
 func (e Error) Type() ObjectType {
 	return ERROR_OBJ
 }
 
+// 位置情報があれば、呼び出し履歴とあわせてトレースバック形式で出力する
+//
+//	ERROR: identifier not found: y (line 2, column 14)
+//	  in add (called at line 4, column 1)
+//	  ... 98 more frames in add
 func (e Error) Inspect() string {
-	return "ERROR: " + e.Message
+	var out bytes.Buffer
+
+	out.WriteString("ERROR: " + e.Message)
+	if e.Line > 0 {
+		out.WriteString(fmt.Sprintf(" (line %d, column %d)", e.Line, e.Column))
+	}
+	for _, frame := range e.Stack {
+		out.WriteString("\n  " + frame.String())
+		if frame.Repeated > 0 {
+			out.WriteString(fmt.Sprintf("\n  ... %d more frames in %s", frame.Repeated, frame.Function))
+		}
+	}
+	if e.Omitted > 0 {
+		out.WriteString(fmt.Sprintf("\n  ... %d more frames", e.Omitted))
+	}
+
+	return out.String()
+}
+
+// 関数呼び出しひとつ分の情報
+type StackFrame struct {
+	Function string // 呼び出した関数の名前
+	Line     int    // 呼び出した位置
+	Column   int    // 呼び出した位置
+	Repeated int    // このフレームの後に続けて繰り返した同じ呼び出しの数
+}
+
+func (f StackFrame) sameCall(other StackFrame) bool {
+	return f.Function == other.Function && f.Line == other.Line && f.Column == other.Column
+}
+
+func (f StackFrame) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("in %s", f.Function)
+	}
+	return fmt.Sprintf("in %s (called at line %d, column %d)", f.Function, f.Line, f.Column)
 }
 
 type HashPair struct {
@@ -232,14 +499,60 @@ type HashPair struct {
 	Value Object
 }
 
+// 挿入した順序を保つハッシュ
+// ハッシュ値ごとのバケットから位置を引くので、検索と更新は定数時間で行える
+// ハッシュ値が衝突しても別のキーとして扱えるように、バケットの中ではキーの値を比べる
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair        // 挿入した順に並ぶ
+	buckets map[HashKey][]int // ハッシュ値が同じキーのpairsでの位置
 }
 
-func NewHash(pairs map[HashKey]HashPair) *Hash {
+func NewHash() *Hash {
 	return &Hash{
-		Pairs: pairs,
+		pairs:   []HashPair{},
+		buckets: map[HashKey][]int{},
+	}
+}
+
+// キーに値を設定する
+// 既にあるキーの場合は値だけを置き換え、順序は変えない
+func (h *Hash) Set(key Hashable, value Object) {
+	if i, ok := h.lookup(key); ok {
+		h.pairs[i].Value = value
+		return
 	}
+	hashed := key.HashKey()
+	h.buckets[hashed] = append(h.buckets[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// キーのpairsでの位置を返す
+func (h *Hash) lookup(key Hashable) (int, bool) {
+	for _, i := range h.buckets[key.HashKey()] {
+		if equalKeys(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// キーと値の組を挿入した順に返す
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.pairs))
+	copy(pairs, h.pairs)
+	return pairs
 }
 
 var _ Object = (*Hash)(nil)
@@ -252,7 +565,7 @@ func (h Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -263,6 +576,15 @@ func (h Hash) Inspect() string {
 	return out.String()
 }
 
+// キーを挿入した順に返す
+func (h *Hash) Keys() []Object {
+	keys := make([]Object, 0, len(h.pairs))
+	for _, pair := range h.pairs {
+		keys = append(keys, pair.Key)
+	}
+	return keys
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -287,11 +609,35 @@ func (i *Integer) HashKey() HashKey {
 }
 
 func (s *String) HashKey() HashKey {
+	return newHashKey(s.Type(), stringHash(s.Value))
+}
+
+// 文字列のハッシュ値を計算する
+// テストでハッシュ値を衝突させるために差し替えられるようにしておく
+var stringHash = func(s string) uint64 {
 	h := fnv.New64a()
-	h.Write([]byte(s.Value))
-	return newHashKey(s.Type(), h.Sum64())
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// ハッシュ値が同じキーについて、値まで等しいかを確かめる
+func equalKeys(a Object, b Object) bool {
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	default:
+		return false
+	}
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
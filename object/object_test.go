@@ -58,3 +58,147 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestRegistry(t *testing.T) {
+	identity := func(ctx *ExecContext, args ...Object) Object { return args[0] }
+
+	r := NewDefaultRegistry()
+	if err := r.Register(NewBuiltin("len", 1, "", identity)); err == nil {
+		t.Errorf("expected error when registering duplicate builtin")
+	}
+
+	r.Override(NewBuiltin("len", 1, "", identity))
+	builtin, ok := r.Builtin("len")
+	if !ok || builtin.Call(DefaultExecContext(), NewInteger(5)).(*Integer).Value != 5 {
+		t.Errorf("builtin was not overridden")
+	}
+
+	ns, err := r.Namespace("config")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ns.Register(NewBuiltin("get", 1, "", identity)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	obj, ok := r.Lookup("config")
+	if !ok {
+		t.Fatalf("namespace not found")
+	}
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("namespace is not Hash. got=%T", obj)
+	}
+	if _, ok := hash.Get(NewString("get")); !ok {
+		t.Errorf("namespace does not contain get")
+	}
+	if err := r.Register(NewBuiltin("config", 0, "", identity)); err == nil {
+		t.Errorf("expected error when registering builtin with namespace name")
+	}
+
+	clone := r.Clone()
+	clone.Remove("len")
+	if _, ok := r.Lookup("len"); !ok {
+		t.Errorf("removing from clone affected original registry")
+	}
+}
+
+func TestBuiltinArity(t *testing.T) {
+	builtin := NewBuiltin("pair", 2, "", func(ctx *ExecContext, args ...Object) Object { return NULL })
+
+	result := builtin.Call(DefaultExecContext(), NewInteger(1))
+	err, ok := result.(*Error)
+	if !ok {
+		t.Fatalf("result is not Error. got=%T", result)
+	}
+	if err.Message != "wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+
+	variadic := NewBuiltin("any", VariadicArity, "", func(ctx *ExecContext, args ...Object) Object { return NULL })
+	if variadic.Call(DefaultExecContext(), NewInteger(1), NewInteger(2), NewInteger(3)) != NULL {
+		t.Errorf("variadic builtin rejected arguments")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	hash.Set(NewString("b"), NewInteger(1))
+	hash.Set(NewInteger(2), NewInteger(2))
+	hash.Set(TRUE, NewInteger(3))
+	hash.Set(NewString("b"), NewInteger(4))
+
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length. got=%d", hash.Len())
+	}
+	if hash.Inspect() != "{b: 4, 2: 2, true: 3}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+
+	value, ok := hash.Get(NewInteger(2))
+	if !ok || value.Inspect() != "2" {
+		t.Errorf("wrong value for key 2. got=%v, ok=%t", value, ok)
+	}
+	if _, ok := hash.Get(NewString("missing")); ok {
+		t.Errorf("missing key should not be found")
+	}
+
+	// Pairsの戻り値を変更してもハッシュには影響しない
+	pairs := hash.Pairs()
+	pairs[0].Value = NULL
+	if value, _ := hash.Get(NewString("b")); value.Inspect() != "4" {
+		t.Errorf("hash was modified through Pairs(). got=%s", value.Inspect())
+	}
+}
+
+// 文字列のハッシュ値をすべて同じ値にして、わざと衝突を起こす
+// テストが終わると元のハッシュ関数に戻す
+func forceStringHashCollisions(t *testing.T) {
+	t.Helper()
+	original := stringHash
+	stringHash = func(string) uint64 { return 42 }
+	t.Cleanup(func() { stringHash = original })
+}
+
+func TestHashCollisions(t *testing.T) {
+	forceStringHashCollisions(t)
+
+	a, b := NewString("a"), NewString("b")
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("hash keys should collide")
+	}
+
+	hash := NewHash()
+	hash.Set(a, NewInteger(1))
+	hash.Set(b, NewInteger(2))
+	hash.Set(NewInteger(42), NewInteger(3))
+	hash.Set(NewString("a"), NewInteger(4))
+
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length. got=%d", hash.Len())
+	}
+	if hash.Inspect() != "{a: 4, b: 2, 42: 3}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected string
+		found    bool
+	}{
+		{NewString("a"), "4", true},
+		{NewString("b"), "2", true},
+		{NewInteger(42), "3", true},
+		{NewString("c"), "", false},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if ok != tt.found {
+			t.Errorf("wrong lookup result for %s. got=%t, want=%t", tt.key.Inspect(), ok, tt.found)
+			continue
+		}
+		if ok && value.Inspect() != tt.expected {
+			t.Errorf("wrong value for %s. got=%s, want=%s", tt.key.Inspect(), value.Inspect(), tt.expected)
+		}
+	}
+}
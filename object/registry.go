@@ -0,0 +1,121 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// スクリプトから参照できる組み込み関数の集合
+// インタプリタごとに別のレジストリを持たせることで、見える組み込み関数を切り替えられる
+type Registry struct {
+	builtins   map[string]*Builtin
+	namespaces map[string]*Registry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		builtins:   map[string]*Builtin{},
+		namespaces: map[string]*Registry{},
+	}
+}
+
+// 標準の組み込み関数を登録したレジストリを作る
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, builtin := range Builtins {
+		r.builtins[builtin.Name] = builtin
+	}
+	return r
+}
+
+// 組み込み関数を登録する
+// 同じ名前の組み込み関数や名前空間がすでにある場合はエラーを返す
+func (r *Registry) Register(builtin *Builtin) error {
+	if _, ok := r.builtins[builtin.Name]; ok {
+		return fmt.Errorf("builtin already registered: %s", builtin.Name)
+	}
+	if _, ok := r.namespaces[builtin.Name]; ok {
+		return fmt.Errorf("name already used by namespace: %s", builtin.Name)
+	}
+	r.builtins[builtin.Name] = builtin
+	return nil
+}
+
+// 組み込み関数を登録する
+// 同じ名前の組み込み関数がすでにある場合は置き換える
+func (r *Registry) Override(builtin *Builtin) {
+	delete(r.namespaces, builtin.Name)
+	r.builtins[builtin.Name] = builtin
+}
+
+// 組み込み関数を取り除く
+func (r *Registry) Remove(name string) {
+	delete(r.builtins, name)
+	delete(r.namespaces, name)
+}
+
+// 名前空間を返す（なければ作る）
+// スクリプトからは関数名をキーとするハッシュとして見え、ns["fn"](x) のように呼び出す
+func (r *Registry) Namespace(name string) (*Registry, error) {
+	if ns, ok := r.namespaces[name]; ok {
+		return ns, nil
+	}
+	if _, ok := r.builtins[name]; ok {
+		return nil, fmt.Errorf("name already used by builtin: %s", name)
+	}
+	ns := NewRegistry()
+	r.namespaces[name] = ns
+	return ns, nil
+}
+
+// 名前に対応する組み込み関数、または名前空間を表すハッシュを返す
+func (r *Registry) Lookup(name string) (Object, bool) {
+	if builtin, ok := r.builtins[name]; ok {
+		return builtin, true
+	}
+	if ns, ok := r.namespaces[name]; ok {
+		return ns.hash(), true
+	}
+	return nil, false
+}
+
+// 登録されている組み込み関数を返す
+func (r *Registry) Builtin(name string) (*Builtin, bool) {
+	builtin, ok := r.builtins[name]
+	return builtin, ok
+}
+
+// 登録されている組み込み関数と名前空間の名前を辞書順で返す
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.builtins)+len(r.namespaces))
+	for name := range r.builtins {
+		names = append(names, name)
+	}
+	for name := range r.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 複製を返す
+// 複製への登録は元のレジストリに影響しない
+func (r *Registry) Clone() *Registry {
+	clone := NewRegistry()
+	for name, builtin := range r.builtins {
+		clone.builtins[name] = builtin
+	}
+	for name, ns := range r.namespaces {
+		clone.namespaces[name] = ns.Clone()
+	}
+	return clone
+}
+
+func (r *Registry) hash() *Hash {
+	hash := NewHash()
+	for _, name := range r.Names() {
+		value, _ := r.Lookup(name)
+		hash.Set(NewString(name), value)
+	}
+	return hash
+}
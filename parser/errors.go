@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// 構文解析エラー
+// 位置情報は1始まりの行番号と列番号
+type ParseError struct {
+	Message    string
+	Expected   token.TokenType // 期待したトークンの種類（特定できない場合は空）
+	Got        token.TokenType // 実際に現れたトークンの種類
+	Literal    string          // 実際に現れたトークンの文字列
+	Line       int
+	Column     int
+	SourceLine string // エラー箇所を含む行のソースコード
+}
+
+func (p *Parser) newParseError(message string, expected token.TokenType, tok *token.Token) *ParseError {
+	err := &ParseError{
+		Message:  message,
+		Expected: expected,
+		Got:      tok.Type,
+		Literal:  tok.Literal,
+	}
+	if detail := tok.Detail(); detail != nil {
+		err.Line = detail.LineNumber
+		err.Column = detail.ColumnNumber
+		err.SourceLine = sourceLine(p.Input(), detail.LineNumber)
+	}
+	return err
+}
+
+// 同じ間違いから派生するエラーを報告しないように、
+// 文の区切りまで読み飛ばすまでは最初のエラーだけを記録する
+func (p *Parser) addError(err *ParseError) {
+	if p.panicking {
+		return
+	}
+	p.errors = append(p.errors, err)
+	p.panicking = true
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// エラー箇所に下線を引いたソースコードを返す
+//
+//	let x = ;
+//	        ^
+func (e *ParseError) Caret() string {
+	if e.Line == 0 {
+		return ""
+	}
+
+	// タブ幅がずれないように、エラー箇所より前のタブはそのまま残す
+	// 列番号は文字数で数えているので、バイト位置ではなく文字単位で進める
+	var padding strings.Builder
+	column := 1
+	for _, ch := range e.SourceLine {
+		if column >= e.Column {
+			break
+		}
+		column++
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	// 複数行にわたるトークンは最初の行だけに下線を引く
+	literal := e.Literal
+	if i := strings.IndexByte(literal, '\n'); i >= 0 {
+		literal = literal[:i]
+	}
+	width := utf8.RuneCountInString(literal)
+	if width == 0 {
+		width = 1
+	}
+
+	return e.SourceLine + "\n" + padding.String() + strings.Repeat("^", width)
+}
+
+func sourceLine(input string, lineNumber int) string {
+	lines := strings.Split(input, "\n")
+	if lineNumber < 1 || lineNumber > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[lineNumber-1], "\r")
+}
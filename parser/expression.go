@@ -2,7 +2,6 @@ package parser
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"monkey/ast"
 	"monkey/token"
 	"strconv"
@@ -35,8 +34,17 @@ func (p *Parser) parseExpression(precedence precedence) ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	message := fmt.Sprintf("no prefix parse function for %q found", t)
-	err := errors.New(message)
-	p.errors = append(p.errors, err)
+	p.addError(p.newParseError(message, "", p.currentToken))
+}
+
+// 字句解析できなかったトークンを報告する
+func (p *Parser) parseIllegal() ast.Expression {
+	message := p.currentToken.Message()
+	if message == "" {
+		message = fmt.Sprintf("illegal character %q", p.currentToken.Literal)
+	}
+	p.addError(p.newParseError(message, "", p.currentToken))
+	return nil
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -52,9 +60,8 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 	if err != nil {
-		message := fmt.Sprintf("could not parse %q as integer", p.peekToken.Literal)
-		err := errors.New(message)
-		p.errors = append(p.errors, err)
+		message := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
+		p.addError(p.newParseError(message, "", p.currentToken))
 		return nil
 	}
 
@@ -63,6 +70,21 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	trace(fmt.Sprintf("parseFloatLiteral(): {%s}", p.debug()))
+
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	if err != nil {
+		message := fmt.Sprintf("could not parse %q as float", p.currentToken.Literal)
+		p.addError(p.newParseError(message, "", p.currentToken))
+		return nil
+	}
+
+	expression := ast.NewFloatLiteral(p.currentToken, value)
+	untrace(fmt.Sprintf("parseFloatLiteral() => return FloatLiteral{%q}", expression))
+	return expression
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	trace(fmt.Sprintf("parseArrayLiteral(): {%s}", p.debug()))
 
@@ -70,6 +92,9 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 	elements := p.parseExpressionList(token.RBRACKET)
 	expression.SetElements(elements)
+	if p.currentTokenIs(token.RBRACKET) {
+		expression.SetRbracket(p.currentToken)
+	}
 
 	untrace(fmt.Sprintf("parseArrayLiteral() => return ArrayLiteral{%q}", expression))
 	return expression
@@ -101,6 +126,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.SetRbrace(p.currentToken)
 
 	untrace(fmt.Sprintf("parseHashLiteral() => return HashLiteral{%q}", hash))
 	return hash
@@ -161,6 +187,28 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	trace(fmt.Sprintf("parseAssignExpression(target=%q): {%s}", target, p.debug()))
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		message := fmt.Sprintf("invalid assignment target: %s", target)
+		p.addError(p.newParseError(message, "", p.currentToken))
+		return nil
+	}
+
+	expression := ast.NewAssignExpression(p.currentToken, target)
+	p.nextToken()
+
+	// a = b = 1 を a = (b = 1) とするため、右結合で解析する
+	value := p.parseExpression(ASSIGNMENT - 1)
+	expression.SetValue(value)
+
+	untrace(fmt.Sprintf("parseAssignExpression() => return AssignExpression{%q}", expression))
+	return expression
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	trace(fmt.Sprintf("parseIfExpression(): {%s}", p.debug()))
 
@@ -187,18 +235,44 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
-		if !p.expectPeek(token.LBRACE) {
-			return nil
+		// else if は後続のif式だけを含むブロックとして扱う
+		// 評価器やコンパイラは通常のelseブロックと同じように処理できる
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			alternative := p.parseElseIf()
+			if alternative == nil {
+				return nil
+			}
+			expression.SetAlternative(alternative)
+		} else {
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+
+			alternative := p.parseBlockStatement()
+			expression.SetAlternative(alternative)
 		}
-
-		alternative := p.parseBlockStatement()
-		expression.SetAlternative(alternative)
 	}
 
 	untrace(fmt.Sprintf("parseIfExpression() => return Expression{%q}", expression))
 	return expression
 }
 
+// else if の後続のif式を、それだけを含むブロックとして構文解析する
+// ブロックのトークンにはifトークンを使う
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	block := ast.NewBlockStatement(p.currentToken)
+	stmt := ast.NewExpressionStatement(p.currentToken)
+
+	next := p.parseIfExpression()
+	if next == nil {
+		return nil
+	}
+	stmt.SetExpression(next)
+	block.AddStatement(stmt)
+	return block
+}
+
 func (p *Parser) parseFunctionExpression() ast.Expression {
 	trace(fmt.Sprintf("parseFunctionExpression(): {%s}", p.debug()))
 
@@ -221,6 +295,28 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	trace(fmt.Sprintf("parseMacroLiteral(): {%s}", p.debug()))
+
+	expression := ast.NewMacroLiteral(p.currentToken)
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	parameters := p.parseFunctionParameters()
+	expression.SetParameters(parameters)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseBlockStatement()
+	expression.SetBody(body)
+
+	untrace(fmt.Sprintf("parseMacroLiteral() => return Expression{%q}", expression))
+	return expression
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	trace(fmt.Sprintf("parseFunctionParameters(): {%s}", p.debug()))
 
@@ -257,6 +353,9 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 
 	args := p.parseExpressionList(token.RPAREN)
 	expression.SetArguments(args)
+	if p.currentTokenIs(token.RPAREN) {
+		expression.SetRparen(p.currentToken)
+	}
 
 	untrace(fmt.Sprintf("parseCallExpression() => return Expression{%q}", expression))
 	return expression
@@ -303,6 +402,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	expression.SetRbracket(p.currentToken)
 
 	untrace(fmt.Sprintf("parseIndexExpression() => return IndexExpression{%q}", expression))
 	return expression
@@ -312,8 +412,10 @@ func (p *Parser) initExpressionFunctions() {
 	p.prefixParseFns = map[token.TokenType]prefixParseFn{}
 	p.infixParseFns = map[token.TokenType]infixParseFn{}
 
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 
@@ -323,6 +425,7 @@ func (p *Parser) initExpressionFunctions() {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -335,6 +438,17 @@ func (p *Parser) initExpressionFunctions() {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
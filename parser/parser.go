@@ -2,7 +2,6 @@ package parser
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
@@ -15,6 +14,9 @@ type precedence int
 const (
 	_ precedence = iota
 	LOWEST
+	ASSIGNMENT  // = or +=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -25,23 +27,35 @@ const (
 )
 
 var precedences = map[token.TokenType]precedence{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGNMENT,
+	token.PLUS_ASSIGN:     ASSIGNMENT,
+	token.MINUS_ASSIGN:    ASSIGNMENT,
+	token.ASTERISK_ASSIGN: ASSIGNMENT,
+	token.SLASH_ASSIGN:    ASSIGNMENT,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.AND:             LOGICAL_AND,
+	token.OR:              LOGICAL_OR,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 type Parser struct {
 	l            *lexer.Lexer
 	currentToken *token.Token
 	peekToken    *token.Token
-	errors       []error
+	errors       []*ParseError
+	panicking    bool // エラーから復帰するまでの間はtrue
+	braceDepth   int  // currentTokenより前で開いたままの'{'の数（currentTokenが'}'なら閉じた後の数）
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -50,7 +64,7 @@ type Parser struct {
 func NewParser(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []error{},
+		errors: []*ParseError{},
 	}
 
 	// 2つトークンを読み込む
@@ -65,8 +79,19 @@ func NewParser(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	if p.currentToken != nil && p.currentTokenIs(token.LBRACE) {
+		p.braceDepth++
+	}
 	p.currentToken = p.peekToken
+	if p.currentToken != nil && p.currentTokenIs(token.RBRACE) {
+		p.braceDepth--
+	}
 	p.peekToken = p.l.NextToken()
+
+	// コメントを返す字句解析器が渡された場合も、コメントは構文に含めない
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) currentTokenIs(t token.TokenType) bool {
@@ -101,12 +126,16 @@ func (p *Parser) currentPrecedence() precedence {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	message := fmt.Sprintf("expected next token to be '%s', got: '%s', detail: %s", t, p.peekToken.Type, p.peekToken.Detail())
-	err := errors.New(message)
-	p.errors = append(p.errors, err)
+	// 字句解析でエラーになった場合は、その理由を報告する
+	if p.peekToken.Type == token.ILLEGAL && p.peekToken.Message() != "" {
+		p.addError(p.newParseError(p.peekToken.Message(), t, p.peekToken))
+		return
+	}
+	message := fmt.Sprintf("expected next token to be '%s', got: '%s'", t, p.peekToken.Type)
+	p.addError(p.newParseError(message, t, p.peekToken))
 }
 
-func (p *Parser) Errors() []error {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
@@ -216,6 +216,28 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	p := parser.NewParser(lexer.NewLexer("3.14;"))
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	got := program.Statements[0]
+	want := &ast.ExpressionStatement{
+		Token:      token.NewFloatToken("3.14"),
+		Expression: ast.NewFloatLiteral(token.NewFloatToken("3.14"), 3.14),
+	}
+
+	opt := cmpopts.IgnoreUnexported(token.Token{})
+	if diff := cmp.Diff(got, want, opt); diff != "" {
+		t.Errorf("failed statement: diff (-got +want):\n%s", diff)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	cases := []struct {
 		input string
@@ -411,6 +433,7 @@ func TestIfExpression(t *testing.T) {
 					Statements: []ast.Statement{
 						newIdentifierExpressionStatement("x"),
 					},
+					Rbrace: rbraceToken,
 				},
 			},
 		},
@@ -445,6 +468,90 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestLoopStatements(t *testing.T) {
+	cases := []struct {
+		input string
+		want  ast.Statement
+	}{
+		{
+			input: "while (x < y) { x; break; }",
+			want: &ast.WhileStatement{
+				Token: token.NewIdentifierToken("while"),
+				Condition: newInfixExpression(
+					ast.NewIdentifierByName("x"),
+					ltToken,
+					ast.NewIdentifierByName("y"),
+				),
+				Body: &ast.BlockStatement{
+					Token: token.NewToken(token.LBRACE, "{"),
+					Statements: []ast.Statement{
+						newIdentifierExpressionStatement("x"),
+						ast.NewBreakStatement(token.NewIdentifierToken("break")),
+					},
+					Rbrace: rbraceToken,
+				},
+			},
+		},
+		{
+			input: "for (x in xs) { continue }",
+			want: &ast.ForStatement{
+				Token:    token.NewIdentifierToken("for"),
+				Variable: ast.NewIdentifierByName("x"),
+				Iterable: ast.NewIdentifierByName("xs"),
+				Body: &ast.BlockStatement{
+					Token: token.NewToken(token.LBRACE, "{"),
+					Statements: []ast.Statement{
+						ast.NewContinueStatement(token.NewIdentifierToken("continue")),
+					},
+					Rbrace: rbraceToken,
+				},
+			},
+		},
+		{
+			// 本体の後の';'は省略できる
+			input: "while (x) { };",
+			want: &ast.WhileStatement{
+				Token:     token.NewIdentifierToken("while"),
+				Condition: ast.NewIdentifierByName("x"),
+				Body: &ast.BlockStatement{
+					Token:      token.NewToken(token.LBRACE, "{"),
+					Statements: []ast.Statement{},
+					Rbrace:     rbraceToken,
+				},
+			},
+		},
+		{
+			input: "for (x in xs) { };",
+			want: &ast.ForStatement{
+				Token:    token.NewIdentifierToken("for"),
+				Variable: ast.NewIdentifierByName("x"),
+				Iterable: ast.NewIdentifierByName("xs"),
+				Body: &ast.BlockStatement{
+					Token:      token.NewToken(token.LBRACE, "{"),
+					Statements: []ast.Statement{},
+					Rbrace:     rbraceToken,
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		p := parser.NewParser(lexer.NewLexer(tc.input))
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		opt := cmpopts.IgnoreUnexported(token.Token{})
+		if diff := cmp.Diff(program.Statements[0], tc.want, opt); diff != "" {
+			t.Errorf("failed statement %q, diff (-got +want):\n%s", tc.input, diff)
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	cases := []struct {
 		input string
@@ -464,12 +571,62 @@ func TestIfElseExpression(t *testing.T) {
 					Statements: []ast.Statement{
 						newIdentifierExpressionStatement("x"),
 					},
+					Rbrace: rbraceToken,
 				},
 				Alternative: &ast.BlockStatement{
 					Token: token.NewToken(token.LBRACE, "{"),
 					Statements: []ast.Statement{
 						newIdentifierExpressionStatement("y"),
 					},
+					Rbrace: rbraceToken,
+				},
+			},
+		},
+		{
+			input: "if (x < y) { x } else if (x > y) { y } else { z }",
+			want: &ast.IfExpression{
+				Token: token.NewIdentifierToken("if"),
+				Condition: newInfixExpression(
+					ast.NewIdentifierByName("x"),
+					ltToken,
+					ast.NewIdentifierByName("y"),
+				),
+				Consequence: &ast.BlockStatement{
+					Token: token.NewToken(token.LBRACE, "{"),
+					Statements: []ast.Statement{
+						newIdentifierExpressionStatement("x"),
+					},
+					Rbrace: rbraceToken,
+				},
+				Alternative: &ast.BlockStatement{
+					Token: token.NewIdentifierToken("if"),
+					Statements: []ast.Statement{
+						&ast.ExpressionStatement{
+							Token: token.NewIdentifierToken("if"),
+							Expression: &ast.IfExpression{
+								Token: token.NewIdentifierToken("if"),
+								Condition: newInfixExpression(
+									ast.NewIdentifierByName("x"),
+									token.NewTokenByChar(token.GT, '>'),
+									ast.NewIdentifierByName("y"),
+								),
+								Consequence: &ast.BlockStatement{
+									Token: token.NewToken(token.LBRACE, "{"),
+									Statements: []ast.Statement{
+										newIdentifierExpressionStatement("y"),
+									},
+									Rbrace: rbraceToken,
+								},
+								Alternative: &ast.BlockStatement{
+									Token: token.NewToken(token.LBRACE, "{"),
+									Statements: []ast.Statement{
+										newIdentifierExpressionStatement("z"),
+									},
+									Rbrace: rbraceToken,
+								},
+							},
+						},
+					},
 				},
 			},
 		},
@@ -529,6 +686,7 @@ func TestFunctionLiteral(t *testing.T) {
 							),
 						},
 					},
+					Rbrace: rbraceToken,
 				},
 			},
 		},
@@ -540,6 +698,7 @@ func TestFunctionLiteral(t *testing.T) {
 				Body: &ast.BlockStatement{
 					Token:      token.NewToken(token.LBRACE, "{"),
 					Statements: []ast.Statement{},
+					Rbrace:     rbraceToken,
 				},
 			},
 		},
@@ -553,6 +712,7 @@ func TestFunctionLiteral(t *testing.T) {
 				Body: &ast.BlockStatement{
 					Token:      token.NewToken(token.LBRACE, "{"),
 					Statements: []ast.Statement{},
+					Rbrace:     rbraceToken,
 				},
 			},
 		},
@@ -567,6 +727,7 @@ func TestFunctionLiteral(t *testing.T) {
 				Body: &ast.BlockStatement{
 					Token:      token.NewToken(token.LBRACE, "{"),
 					Statements: []ast.Statement{},
+					Rbrace:     rbraceToken,
 				},
 			},
 		},
@@ -601,6 +762,55 @@ func TestFunctionLiteral(t *testing.T) {
 	}
 }
 
+func TestMacroLiteral(t *testing.T) {
+	input := "macro(x, y) { x + y; }"
+	want := &ast.MacroLiteral{
+		Token: token.NewIdentifierToken("macro"),
+		Parameters: []*ast.Identifier{
+			ast.NewIdentifierByName("x"),
+			ast.NewIdentifierByName("y"),
+		},
+		Body: &ast.BlockStatement{
+			Token: token.NewToken(token.LBRACE, "{"),
+			Statements: []ast.Statement{
+				&ast.ExpressionStatement{
+					Token: token.NewIdentifierToken("x"),
+					Expression: newInfixExpression(
+						ast.NewIdentifierByName("x"),
+						plusToken,
+						ast.NewIdentifierByName("y"),
+					),
+				},
+			},
+			Rbrace: rbraceToken,
+		},
+	}
+
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	checkParserError(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.ExpressionStatement: %+v", stmt)
+	}
+
+	exp, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.MacroLiteral: %+v", exp)
+	}
+
+	opt := cmpopts.IgnoreUnexported(*exp.Token)
+	if diff := cmp.Diff(exp, want, opt); diff != "" {
+		t.Errorf("failed statement %q, diff (-got +want):\n%s", input, diff)
+	}
+}
+
 func TestCallExpression(t *testing.T) {
 	cases := []struct {
 		input string
@@ -619,6 +829,7 @@ func TestCallExpression(t *testing.T) {
 					),
 				},
 				Function: ast.NewIdentifierByName("add"),
+				Rparen:   rparenToken,
 			},
 		},
 	}
@@ -661,6 +872,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4; -5 * 5",
 			"(3 + 4)((-5) * 5)",
 		},
+		{
+			"if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }",
+			"ifa 1else ifb 2else ifc 3else4",
+		},
+		{
+			"if (a) { 1 } else { if (b) { 2 } }",
+			"ifa 1elseifb 2",
+		},
 		{
 			"5 > 4 == 3 < 4",
 			"((5 > 4) == (3 < 4))",
@@ -673,6 +892,34 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"x = y = 1 + 2",
+			"x = y = (1 + 2)",
+		},
+		{
+			"a[0] += b * 2",
+			"(a[0]) += (b * 2)",
+		},
+		{
+			"x = a || b",
+			"x = (a || b)",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || !c && d",
+			"((a && b) || ((!c) && d))",
+		},
 		{
 			"true",
 			"true",
@@ -856,6 +1103,7 @@ func TestParsingIndexExpressions(t *testing.T) {
 					plusToken,
 					ast.NewIntegerLiteralByValue(1),
 				),
+				Rbracket: rbracketToken,
 			},
 		},
 	}
@@ -914,25 +1162,35 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+	// ペアはソースコードに書いた順に並ぶ
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
 
 	if len(hash.Pairs) != len(expected) {
-		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		if literal.String() != expected[i].key {
+			t.Errorf("hash.Pairs[%d] has wrong key. got=%q, want=%q", i, literal.String(), expected[i].key)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+
+	if hash.String() != "{one:1, two:2, three:3}" {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
 	}
 }
 
@@ -965,10 +1223,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 
@@ -978,7 +1236,278 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			continue
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, b) { a /* left */ + b }; // trailing
+add(1, 2);`
+
+	for _, options := range [][]lexer.Option{nil, {lexer.WithComments()}} {
+		p := parser.NewParser(lexer.NewLexer(input, options...))
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		want := "let add = fn(a,b)(a + b);add(1, 2)"
+		if program.String() != want {
+			t.Errorf("program.String() wrong. want=%q, got=%q", want, program.String())
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Offset: offset, Line: line, Column: column}
+	}
+
+	cases := []struct {
+		input string
+		pos   token.Position
+		end   token.Position
+	}{
+		{"let x = 5;", pos(0, 1, 1), pos(9, 1, 10)},
+		{"return add(1, 2);", pos(0, 1, 1), pos(16, 1, 17)},
+		{"  x + y", pos(2, 1, 3), pos(7, 1, 8)},
+		{"-a * b", pos(0, 1, 1), pos(6, 1, 7)},
+		{"a[1 + 2]", pos(0, 1, 1), pos(8, 1, 9)},
+		{"[1, 2]", pos(0, 1, 1), pos(6, 1, 7)},
+		{`{"a": 1}`, pos(0, 1, 1), pos(8, 1, 9)},
+		{"x += 1", pos(0, 1, 1), pos(6, 1, 7)},
+		{"fn(x) { x }", pos(0, 1, 1), pos(11, 1, 12)},
+		{"if (x) { 1 } else { 2 }", pos(0, 1, 1), pos(23, 1, 24)},
+		{"if (x) { 1 } else if (y) { 2 }", pos(0, 1, 1), pos(30, 1, 31)},
+		{"while (x) { break; }", pos(0, 1, 1), pos(20, 1, 21)},
+		{"for (x in xs) { continue }", pos(0, 1, 1), pos(26, 1, 27)},
+		{`"héllo"`, pos(0, 1, 1), pos(8, 1, 8)},
+		{"let 名前 = 1.5", pos(0, 1, 1), pos(16, 1, 13)},
+		{"fn() {\n  1\n}", pos(0, 1, 1), pos(12, 3, 2)},
+		{"1;\n  true", pos(0, 1, 1), pos(9, 2, 7)},
+	}
+
+	for _, tc := range cases {
+		p := parser.NewParser(lexer.NewLexer(tc.input))
+		program := p.ParseProgram()
+		checkParserError(t, p)
+
+		if program.Pos() != tc.pos || program.End() != tc.end {
+			t.Errorf("wrong span for %q. got=%+v-%+v, want=%+v-%+v",
+				tc.input, program.Pos(), program.End(), tc.pos, tc.end)
+		}
+
+		// 子ノードは親ノードの範囲に収まる
+		var parents []ast.Node
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node == nil {
+				parents = parents[:len(parents)-1]
+				return true
+			}
+			if !node.Pos().IsValid() || node.Pos().Offset > node.End().Offset {
+				t.Errorf("invalid span for %q in %q. got=%+v-%+v", node, tc.input, node.Pos(), node.End())
+			}
+			if len(parents) > 0 {
+				parent := parents[len(parents)-1]
+				if node.Pos().Offset < parent.Pos().Offset || node.End().Offset > parent.End().Offset {
+					t.Errorf("span of %q is outside of its parent %q in %q", node, parent, tc.input)
+				}
+			}
+			parents = append(parents, node)
+			return true
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		want  *parser.ParseError
+		caret string
+	}{
+		{
+			input: "let x 5;",
+			want: &parser.ParseError{
+				Message:    "expected next token to be '=', got: 'INT'",
+				Expected:   token.ASSIGN,
+				Got:        token.INT,
+				Literal:    "5",
+				Line:       1,
+				Column:     7,
+				SourceLine: "let x 5;",
+			},
+			caret: "let x 5;\n      ^",
+		},
+		{
+			input: "let x = 1;\n\tlet foo = (x + 1;",
+			want: &parser.ParseError{
+				Message:    "expected next token to be ')', got: ';'",
+				Expected:   token.RPAREN,
+				Got:        token.SEMICOLON,
+				Literal:    ";",
+				Line:       2,
+				Column:     18,
+				SourceLine: "\tlet foo = (x + 1;",
+			},
+			caret: "\tlet foo = (x + 1;\n\t                ^",
+		},
+		{
+			input: "let x = ;",
+			want: &parser.ParseError{
+				Message:    "no prefix parse function for \";\" found",
+				Got:        token.SEMICOLON,
+				Literal:    ";",
+				Line:       1,
+				Column:     9,
+				SourceLine: "let x = ;",
+			},
+			caret: "let x = ;\n        ^",
+		},
+		{
+			input: `let 名前 "モンキー";`,
+			want: &parser.ParseError{
+				Message:    "expected next token to be '=', got: 'STRING'",
+				Expected:   token.ASSIGN,
+				Got:        token.STRING,
+				Literal:    "モンキー",
+				Line:       1,
+				Column:     8,
+				SourceLine: `let 名前 "モンキー";`,
+			},
+			caret: "let 名前 \"モンキー\";\n       ^^^^",
+		},
+		{
+			input: "1 + 2 = 3;",
+			want: &parser.ParseError{
+				Message:    "invalid assignment target: (1 + 2)",
+				Got:        token.ASSIGN,
+				Literal:    "=",
+				Line:       1,
+				Column:     7,
+				SourceLine: "1 + 2 = 3;",
+			},
+			caret: "1 + 2 = 3;\n      ^",
+		},
+		{
+			input: `let s = "abc`,
+			want: &parser.ParseError{
+				Message:    "unterminated string",
+				Got:        token.ILLEGAL,
+				Literal:    `"abc`,
+				Line:       1,
+				Column:     9,
+				SourceLine: `let s = "abc`,
+			},
+			caret: "let s = \"abc\n        ^^^^",
+		},
+		{
+			input: `puts("a\qb");`,
+			want: &parser.ParseError{
+				Message:    "unknown escape sequence: \\q",
+				Got:        token.ILLEGAL,
+				Literal:    `"a\qb"`,
+				Line:       1,
+				Column:     6,
+				SourceLine: `puts("a\qb");`,
+			},
+			caret: "puts(\"a\\qb\");\n     ^^^^^^",
+		},
+	}
+
+	for _, tc := range cases {
+		p := parser.NewParser(lexer.NewLexer(tc.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors, input = %q", tc.input)
+		}
+
+		if diff := cmp.Diff(errors[0], tc.want); diff != "" {
+			t.Errorf("failed input %q, diff (-got +want):\n%s", tc.input, diff)
+		}
+
+		if errors[0].Caret() != tc.caret {
+			t.Errorf("wrong caret. want=%q, got=%q", tc.caret, errors[0].Caret())
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	cases := []struct {
+		input      string
+		wantErrors []string
+		wantStmts  []string
+	}{
+		{
+			input:      "let x = add(1, 2;\nlet y = 3;\ny;",
+			wantErrors: []string{"1:17: expected next token to be ')', got: ';'"},
+			wantStmts:  []string{"let y = 3;", "y"},
+		},
+		{
+			input: "let = 1; let a = 1;\nlet b = ;\nreturn a;",
+			wantErrors: []string{
+				"1:5: expected next token to be 'IDENT', got: '='",
+				"2:9: no prefix parse function for \";\" found",
+			},
+			wantStmts: []string{"let a = 1;", "return a;"},
+		},
+		{
+			input:      "let f = fn(x) { let y = ; x };\nf(1);",
+			wantErrors: []string{"1:25: no prefix parse function for \";\" found"},
+			// 内側の文のエラーで外側の文も壊れているので、外側の文ごと取り除く
+			wantStmts: []string{"f(1)"},
+		},
+		{
+			input:      "let h = {1: 2, 3 4};\nlet y = 1;",
+			wantErrors: []string{"1:18: expected next token to be ':', got: 'INT'"},
+			wantStmts:  []string{"let y = 1;"},
+		},
+		{
+			input:      "let f = fn(a) { let h = {\"k\" 1}; h };\nlet y = 1;",
+			wantErrors: []string{"1:30: expected next token to be ':', got: 'INT'"},
+			wantStmts:  []string{"let y = 1;"},
+		},
+		{
+			input:      "let a = fn(x { x };\nlet y = 1;",
+			wantErrors: []string{"1:14: expected next token to be ')', got: '{'"},
+			wantStmts:  []string{"let y = 1;"},
+		},
+		{
+			input:      "let f = fn() { return (1 + 2; };\nlet y = 1;",
+			wantErrors: []string{"1:29: expected next token to be ')', got: ';'"},
+			wantStmts:  []string{"let y = 1;"},
+		},
+		{
+			input:      "if (x { 1 } let z = 2;",
+			wantErrors: []string{"1:7: expected next token to be ')', got: '{'"},
+			wantStmts:  []string{"let z = 2;"},
+		},
+		{
+			input:      "} let z = 2;",
+			wantErrors: []string{"1:1: no prefix parse function for \"}\" found"},
+			wantStmts:  []string{"let z = 2;"},
+		},
+	}
+
+	for _, tc := range cases {
+		p := parser.NewParser(lexer.NewLexer(tc.input))
+		program := p.ParseProgram()
+
+		gotErrors := []string{}
+		for _, err := range p.Errors() {
+			gotErrors = append(gotErrors, err.Error())
+		}
+		if diff := cmp.Diff(gotErrors, tc.wantErrors); diff != "" {
+			t.Errorf("wrong errors for input %q, diff (-got +want):\n%s", tc.input, diff)
+		}
+
+		gotStmts := []string{}
+		for _, stmt := range program.Statements {
+			gotStmts = append(gotStmts, stmt.String())
+		}
+		if diff := cmp.Diff(gotStmts, tc.wantStmts); diff != "" {
+			t.Errorf("wrong statements for input %q, diff (-got +want):\n%s", tc.input, diff)
+		}
 	}
 }
 
@@ -1023,6 +1552,10 @@ var (
 	ltToken       = token.NewToken(token.LT, "<")
 	eqToken       = token.NewToken(token.EQ, "==")
 	notEqToken    = token.NewToken(token.NOT_EQ, "!=")
+
+	rparenToken   = token.NewToken(token.RPAREN, ")")
+	rbraceToken   = token.NewToken(token.RBRACE, "}")
+	rbracketToken = token.NewToken(token.RBRACKET, "]")
 )
 
 func checkParserError(t *testing.T, p *parser.Parser) {
@@ -5,8 +5,7 @@ import "monkey/ast"
 func (p *Parser) ParseProgram() *ast.Program {
 	program := ast.NewProgram()
 	for !p.currentToken.IsEOF() {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt, _ := p.parseStatementWithRecovery(); stmt != nil {
 			program.AddStatement(stmt)
 		}
 		p.nextToken()
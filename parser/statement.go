@@ -11,6 +11,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -21,12 +29,13 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
+	let := p.currentToken
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
 	name := ast.NewIdentifier(p.currentToken)
-	stmt := ast.NewLetStatement(name)
+	stmt := ast.NewLetStatement(let, name)
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -48,9 +57,9 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	if !p.currentTokenIs(token.RETURN) {
 		return nil
 	}
+	stmt := ast.NewReturnStatement(p.currentToken)
 	p.nextToken()
 
-	stmt := ast.NewReturnStatement()
 	expression := p.parseExpression(LOWEST)
 	stmt.SetReturnValue(expression)
 
@@ -61,6 +70,91 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// while (condition) { ... }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := ast.NewWhileStatement(p.currentToken)
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	condition := p.parseExpression(LOWEST)
+	stmt.SetCondition(condition)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseBlockStatement()
+	stmt.SetBody(body)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// for (variable in iterable) { ... }
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := ast.NewForStatement(p.currentToken)
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.SetVariable(ast.NewIdentifier(p.currentToken))
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	iterable := p.parseExpression(LOWEST)
+	stmt.SetIterable(iterable)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseBlockStatement()
+	stmt.SetBody(body)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := ast.NewBreakStatement(p.currentToken)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := ast.NewContinueStatement(p.currentToken)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := ast.NewExpressionStatement(p.currentToken)
 	exp := p.parseExpression(LOWEST)
@@ -79,12 +173,68 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt, closed := p.parseStatementWithRecovery()
+		if closed {
+			// ブロックを閉じる'}'で止まった場合はブロックの終わりとする
+			break
+		}
 		if stmt != nil {
 			blockStatement.AddStatement(stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.currentTokenIs(token.RBRACE) {
+		blockStatement.SetRbrace(p.currentToken)
+	}
 	return blockStatement
 }
+
+// 文をひとつ解析する
+// 文の途中でエラーが起きた場合は文の終わりまで読み飛ばしてnilを返す
+// 内側のブロックの文で起きて復帰済みのエラーも、この文のエラーとして扱う
+// 外側のブロックを閉じる'}'まで読み飛ばした場合はclosedにtrueを返す
+func (p *Parser) parseStatementWithRecovery() (stmt ast.Statement, closed bool) {
+	errors := len(p.errors)
+	depth := p.braceDepth
+
+	stmt = p.parseStatement()
+	if p.panicking {
+		closed = p.synchronize(depth)
+	}
+	if len(p.errors) > errors {
+		return nil, closed
+	}
+	return stmt, closed
+}
+
+// エラーが起きた文の終わりまでトークンを読み飛ばす
+// depthは文の先頭で開いていた'{'の数で、文の中で開いた'{'を閉じる'}'では止まらない
+// 呼び出し元がnextTokenを呼ぶと次の文の先頭に移動できる位置で止まる
+// 外側のブロックを閉じる'}'で止まった場合はtrueを返す
+func (p *Parser) synchronize(depth int) bool {
+	defer func() { p.panicking = false }()
+
+	for !p.currentTokenIs(token.EOF) {
+		if p.braceDepth < depth {
+			return true
+		}
+		// '{'の直後のトークンは、その'{'で開いたブロックの中にある
+		if p.braceDepth == depth && !p.currentTokenIs(token.LBRACE) {
+			if p.currentTokenIs(token.SEMICOLON) || p.peekTokenIsStatementBoundary() {
+				return false
+			}
+		}
+		p.nextToken()
+	}
+	return false
+}
+
+func (p *Parser) peekTokenIsStatementBoundary() bool {
+	switch p.peekToken.Type {
+	case token.RBRACE, token.LET, token.RETURN, token.WHILE, token.FOR, token.EOF:
+		return true
+	default:
+		return false
+	}
+}
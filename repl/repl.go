@@ -8,6 +8,7 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -15,9 +16,12 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	// スクリプトの出力もREPLと同じ出力先に書き出す
+	e := evaluator.NewEvaluator(evaluator.WithExecContext(object.NewExecContext(in, out, out)))
 
 	for {
-		fmt.Print(PROMPT)
+		io.WriteString(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -37,7 +41,15 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := e.ExpandMacros(program, macroEnv)
+		if err != nil {
+			io.WriteString(out, err.Inspect())
+			io.WriteString(out, "\n")
+			continue
+		}
+
+		evaluated := e.Eval(expanded, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -58,11 +70,14 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []error) {
+func printParserErrors(out io.Writer, errors []*parser.ParseError) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, "  parser errors:\n")
 	for _, err := range errors {
-		io.WriteString(out, fmt.Sprintf("      %+v\n", err))
+		io.WriteString(out, fmt.Sprintf("      %s\n", err.Message))
+		for _, line := range strings.Split(err.Caret(), "\n") {
+			io.WriteString(out, fmt.Sprintf("        %s\n", line))
+		}
 	}
 }
@@ -6,16 +6,47 @@ type DetailToken struct {
 	Line         string
 	LineNumber   int
 	ColumnNumber int
+	Offset       int      // 先頭からのバイト数（0始まり）
+	End          Position // トークンの直後の位置
 }
 
-func NewDetailToken(line string, lineNumber int, columnNumber int) *DetailToken {
+func NewDetailToken(line string, lineNumber int, columnNumber int, offset int) *DetailToken {
 	return &DetailToken{
 		Line:         line,
 		LineNumber:   lineNumber,
 		ColumnNumber: columnNumber,
+		Offset:       offset,
 	}
 }
 
+func (d *DetailToken) SetEnd(end Position) {
+	d.End = end
+}
+
+// トークンの開始位置
+func (d *DetailToken) Pos() Position {
+	return Position{Offset: d.Offset, Line: d.LineNumber, Column: d.ColumnNumber}
+}
+
 func (d *DetailToken) String() string {
 	return fmt.Sprintf("&DetailToken{line: %d, column: %d, line: '%s'}", d.LineNumber, d.ColumnNumber, d.Line)
 }
+
+// ソースコード上の位置
+// 字句解析器を通さずに作ったトークンやノードの位置はゼロ値になる
+type Position struct {
+	Offset int // 先頭からのバイト数（0始まり）
+	Line   int // 行番号（1始まり）
+	Column int // 列番号（1始まり、文字数で数える）
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
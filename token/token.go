@@ -8,6 +8,7 @@ type Token struct {
 	Type    TokenType
 	Literal string
 	detail  *DetailToken
+	message string // ILLEGALトークンになった理由
 }
 
 func NewToken(tokenType TokenType, literal string) *Token {
@@ -17,7 +18,7 @@ func NewToken(tokenType TokenType, literal string) *Token {
 	}
 }
 
-func NewTokenByChar(tokenType TokenType, ch byte) *Token {
+func NewTokenByChar(tokenType TokenType, ch rune) *Token {
 	return NewToken(tokenType, string(ch))
 }
 
@@ -31,10 +32,20 @@ func NewIntegerToken(literal string) *Token {
 	return tok
 }
 
+func NewFloatToken(literal string) *Token {
+	return NewToken(FLOAT, literal)
+}
+
 func NewStringToken(literal string) *Token {
 	return NewToken(STRING, literal)
 }
 
+// 字句解析できなかった箇所を表すトークン
+// messageには構文解析エラーとして報告する理由を設定する
+func NewIllegalToken(literal string, message string) *Token {
+	return &Token{Type: ILLEGAL, Literal: literal, message: message}
+}
+
 func NewEOF() *Token {
 	return &Token{Type: EOF, Literal: ""}
 }
@@ -51,8 +62,28 @@ func (t *Token) Detail() *DetailToken {
 	return t.detail
 }
 
+// トークンの開始位置
+func (t *Token) Pos() Position {
+	if t == nil || t.detail == nil {
+		return Position{}
+	}
+	return t.detail.Pos()
+}
+
+// トークンの直後の位置
+func (t *Token) End() Position {
+	if t == nil || t.detail == nil {
+		return Position{}
+	}
+	return t.detail.End
+}
+
+func (t *Token) Message() string {
+	return t.message
+}
+
 func (t *Token) Debug() string {
-	if t.Type == IDENT || t.Type == INT {
+	if t.Type == IDENT || t.Type == INT || t.Type == FLOAT {
 		return fmt.Sprintf("%s(%q)", t.Type, t.Literal)
 	}
 	return fmt.Sprintf("%q", t.Literal)
@@ -62,10 +93,12 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 	STRING  = "STRING"
+	COMMENT = "COMMENT"
 
 	// 識別子 + リテラル
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 1343456
+	FLOAT = "FLOAT" // 3.14
 
 	// 演算子
 	ASSIGN   = "="
@@ -74,13 +107,24 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	// デリミタ
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -101,16 +145,28 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
 }
 
 func lookupIdentifier(identifier string) TokenType {
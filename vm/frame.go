@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+// 関数呼び出しごとの実行状態
+type Frame struct {
+	cl          *object.Closure
+	ip          int // 命令ポインタ
+	basePointer int // 呼び出し前のスタックポインタ（ローカル束縛の開始位置）
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,802 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // 常に次の空き領域を指す。スタックの先頭はstack[sp-1]
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object
+
+	execCtx *object.ExecContext // 組み込み関数に渡す入出力
+}
+
+func NewVM(bytecode *compiler.Bytecode) *VM {
+	mainFn := object.NewCompiledFunction(bytecode.Instructions, 0, 0)
+	mainClosure := object.NewClosure(mainFn, []object.Object{})
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		globals:     make([]object.Object, GlobalsSize),
+		frames:      frames,
+		framesIndex: 1,
+		execCtx:     object.DefaultExecContext(),
+	}
+}
+
+// REPLのように複数回実行する場合に、グローバル束縛を引き継ぐ
+func NewVMWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	vm := NewVM(bytecode)
+	vm.globals = globals
+	return vm
+}
+
+// 組み込み関数が使う入出力を設定する
+func (vm *VM) SetExecContext(execCtx *object.ExecContext) {
+	vm.execCtx = execCtx
+}
+
+// 最後に評価した式の値を返す
+// 実行時エラーで停止した場合は *object.Error を返す
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// Monkeyの実行時エラー
+// 評価器と同じく *object.Error を結果として実行を打ち切る
+type runtimeError struct {
+	obj *object.Error
+}
+
+func (e *runtimeError) Error() string {
+	return e.obj.Message
+}
+
+func newRuntimeError(format string, a ...interface{}) error {
+	return &runtimeError{obj: object.NewError(fmt.Sprintf(format, a...))}
+}
+
+func (vm *VM) Run() error {
+	err := vm.run()
+	if rerr, ok := err.(*runtimeError); ok {
+		vm.lastPopped = rerr.obj
+		return nil
+	}
+	return err
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterThanOrEqual, code.OpLessThanOrEqual:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+		case code.OpBang:
+			operand := vm.pop()
+			if err := vm.push(object.NewBoolean(!isTruthy(operand))); err != nil {
+				return err
+			}
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+		case code.OpTrue:
+			if err := vm.push(object.TRUE); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(object.FALSE); err != nil {
+				return err
+			}
+		case code.OpNull:
+			if err := vm.push(object.NULL); err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpIter:
+			iter, err := newIterator(vm.pop())
+			if err != nil {
+				return err
+			}
+			if err := vm.push(iter); err != nil {
+				return err
+			}
+		case code.OpNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iter := vm.pop().(*iterator)
+			element, ok := iter.next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+			if err := vm.push(element); err != nil {
+				return err
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.globals[globalIndex]); err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			if err := vm.push(vm.stack[frame.basePointer+int(localIndex)]); err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(object.Builtins[builtinIndex]); err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex]); err != nil {
+				return err
+			}
+		case code.OpNewCell:
+			if err := vm.push(&cell{value: vm.pop()}); err != nil {
+				return err
+			}
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			c := vm.stack[frame.basePointer+int(localIndex)].(*cell)
+			if err := vm.push(c.value); err != nil {
+				return err
+			}
+		case code.OpSetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			c := vm.stack[frame.basePointer+int(localIndex)].(*cell)
+			c.value = vm.pop()
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			c := vm.currentFrame().cl.Free[freeIndex].(*cell)
+			if err := vm.push(c.value); err != nil {
+				return err
+			}
+		case code.OpSetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			c := vm.currentFrame().cl.Free[freeIndex].(*cell)
+			c.value = vm.pop()
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure); err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSetIndex(left, index, value); err != nil {
+				return err
+			}
+		case code.OpUpdateIndex:
+			operator := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeUpdateIndex(operator, left, index, value); err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// トップレベルのreturnはプログラムの終了を意味する
+			if vm.framesIndex == 1 {
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(object.NULL); err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("unhandled opcode: %s", def.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		// 整数と浮動小数点数の演算は浮動小数点数で行う
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left, right)
+	case op == code.OpEqual:
+		return vm.push(object.NewBoolean(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(object.NewBoolean(left != right))
+	case leftType != rightType:
+		return newRuntimeError("type mismatch: %s %s %s", leftType, operatorOf(op), rightType)
+	default:
+		return newRuntimeError("unknown operator: %s %s %s", leftType, operatorOf(op), rightType)
+	}
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(object.NewInteger(leftValue + rightValue))
+	case code.OpSub:
+		return vm.push(object.NewInteger(leftValue - rightValue))
+	case code.OpMul:
+		return vm.push(object.NewInteger(leftValue * rightValue))
+	case code.OpDiv:
+		if rightValue == 0 {
+			return newRuntimeError("division by zero")
+		}
+		return vm.push(object.NewInteger(leftValue / rightValue))
+	case code.OpMod:
+		if rightValue == 0 {
+			return newRuntimeError("division by zero")
+		}
+		return vm.push(object.NewInteger(leftValue % rightValue))
+	case code.OpGreaterThan:
+		return vm.push(object.NewBoolean(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(object.NewBoolean(leftValue < rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(object.NewBoolean(leftValue >= rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(object.NewBoolean(leftValue <= rightValue))
+	case code.OpEqual:
+		return vm.push(object.NewBoolean(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(object.NewBoolean(leftValue != rightValue))
+	default:
+		return newRuntimeError("unknown operator: %s %s %s", left.Type(), operatorOf(op), right.Type())
+	}
+}
+
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue, _ := object.FloatValue(left)
+	rightValue, _ := object.FloatValue(right)
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(object.NewFloat(leftValue + rightValue))
+	case code.OpSub:
+		return vm.push(object.NewFloat(leftValue - rightValue))
+	case code.OpMul:
+		return vm.push(object.NewFloat(leftValue * rightValue))
+	case code.OpDiv:
+		if rightValue == 0 {
+			return newRuntimeError("division by zero")
+		}
+		return vm.push(object.NewFloat(leftValue / rightValue))
+	case code.OpMod:
+		if rightValue == 0 {
+			return newRuntimeError("division by zero")
+		}
+		return vm.push(object.NewFloat(math.Mod(leftValue, rightValue)))
+	case code.OpGreaterThan:
+		return vm.push(object.NewBoolean(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(object.NewBoolean(leftValue < rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(object.NewBoolean(leftValue >= rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(object.NewBoolean(leftValue <= rightValue))
+	case code.OpEqual:
+		return vm.push(object.NewBoolean(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(object.NewBoolean(leftValue != rightValue))
+	default:
+		return newRuntimeError("unknown operator: %s %s %s", left.Type(), operatorOf(op), right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := object.FloatValue(obj)
+	return ok
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(object.NewString(leftValue + rightValue))
+	default:
+		return newRuntimeError("unknown operator: %s %s %s", left.Type(), operatorOf(op), right.Type())
+	}
+}
+
+// エラーメッセージを評価器と揃えるため、オペコードを演算子に戻す
+func operatorOf(op code.Opcode) string {
+	switch op {
+	case code.OpAdd:
+		return "+"
+	case code.OpSub:
+		return "-"
+	case code.OpMul:
+		return "*"
+	case code.OpDiv:
+		return "/"
+	case code.OpMod:
+		return "%"
+	case code.OpGreaterThan:
+		return ">"
+	case code.OpLessThan:
+		return "<"
+	case code.OpGreaterThanOrEqual:
+		return ">="
+	case code.OpLessThanOrEqual:
+		return "<="
+	case code.OpEqual:
+		return "=="
+	case code.OpNotEqual:
+		return "!="
+	default:
+		return fmt.Sprintf("%d", op)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	if float, ok := operand.(*object.Float); ok {
+		return vm.push(object.NewFloat(-float.Value))
+	}
+	if operand.Type() != object.INTEGER_OBJ {
+		return newRuntimeError("unknown operator: -%s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	return vm.push(object.NewInteger(-value))
+}
+
+func (vm *VM) executeIndexExpression(left object.Object, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return newRuntimeError("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array object.Object, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return vm.push(object.NULL)
+	}
+
+	return vm.push(arrayObject.Elements[idx])
+}
+
+func (vm *VM) executeHashIndex(hash object.Object, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newRuntimeError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return vm.push(object.NULL)
+	}
+
+	return vm.push(value)
+}
+
+// 評価器と同じく、配列の範囲外への代入はエラーにする
+func (vm *VM) executeSetIndex(left object.Object, index object.Object, value object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		array := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(array.Elements)) {
+			return newRuntimeError("index out of range: %d", idx)
+		}
+		array.Elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newRuntimeError("unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Set(key, value)
+	default:
+		return newRuntimeError("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
+// 現在の値とvalueにoperatorを適用した結果を代入する
+// ハッシュにキーがない場合、現在の値はnullとする
+func (vm *VM) executeUpdateIndex(operator code.Opcode, left object.Object, index object.Object, value object.Object) error {
+	var current object.Object
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		array := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(array.Elements)) {
+			return newRuntimeError("index out of range: %d", idx)
+		}
+		current = array.Elements[idx]
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newRuntimeError("unusable as hash key: %s", index.Type())
+		}
+		if current, ok = left.(*object.Hash).Get(key); !ok {
+			current = object.NULL
+		}
+	default:
+		return newRuntimeError("index assignment not supported: %s", left.Type())
+	}
+
+	if err := vm.push(current); err != nil {
+		return err
+	}
+	if err := vm.push(value); err != nil {
+		return err
+	}
+	if err := vm.executeBinaryOperation(operator); err != nil {
+		return err
+	}
+	return vm.executeSetIndex(left, index, vm.pop())
+}
+
+func (vm *VM) buildArray(startIndex int, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return object.NewArray(elements)
+}
+
+func (vm *VM) buildHash(startIndex int, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newRuntimeError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newRuntimeError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newRuntimeError("wrong number of arguments. got=%d, want=%d", numArgs, cl.Fn.NumParameters)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("frame overflow")
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	vm.pushFrame(frame)
+
+	// 引数の後ろにローカル束縛の領域を確保する
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Call(vm.execCtx, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if errObj, ok := result.(*object.Error); ok {
+		return &runtimeError{obj: errObj}
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(object.NULL)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(object.NewClosure(function, free))
+}
+
+// クロージャと外側の関数で共有する変数の値
+// 代入すると、同じセルを持つすべての関数から新しい値が見える
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType {
+	return "CELL"
+}
+
+func (c *cell) Inspect() string {
+	return "cell"
+}
+
+// for-inで反復中の要素の一覧
+// コンパイラが隠れた束縛に置くだけで、スクリプトから参照することはない
+type iterator struct {
+	elements []object.Object
+	index    int
+}
+
+// 評価器と同じく、配列は要素、ハッシュは挿入した順のキー、文字列は1文字ずつの文字列を取り出す
+func newIterator(iterable object.Object) (*iterator, error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(iterable.Elements))
+		copy(elements, iterable.Elements)
+		return &iterator{elements: elements}, nil
+	case *object.Hash:
+		return &iterator{elements: iterable.Keys()}, nil
+	case *object.String:
+		elements := []object.Object{}
+		for _, ch := range iterable.Value {
+			elements = append(elements, object.NewString(string(ch)))
+		}
+		return &iterator{elements: elements}, nil
+	default:
+		return nil, newRuntimeError("cannot iterate over %s", iterable.Type())
+	}
+}
+
+func (it *iterator) next() (object.Object, bool) {
+	if it.index >= len(it.elements) {
+		return nil, false
+	}
+	element := it.elements[it.index]
+	it.index++
+	return element, true
+}
+
+func (it *iterator) Type() object.ObjectType {
+	return "ITERATOR"
+}
+
+func (it *iterator) Inspect() string {
+	return "iterator"
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
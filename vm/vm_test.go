@@ -0,0 +1,235 @@
+package vm_test
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"testing"
+)
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, runVM(t, tt.input), tt.expected)
+	}
+}
+
+func TestGlobalsAndClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let add = fn(a, b) { a + b }; add(1, 2);", 3},
+		{"let f = fn() { return 99; 100; }; f();", 99},
+		{"let newAdder = fn(a) { fn(b) { a + b } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{
+			`
+let wrapper = fn() {
+  let countDown = fn(x) {
+    if (x == 0) { return 0; } else { countDown(x - 1); }
+  };
+  countDown(1);
+};
+wrapper();
+`,
+			0,
+		},
+		{"if (10 > 1) { return 10; } 9;", 10},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }(); counter(); counter(); counter()", 3},
+		{"let make = fn() { let n = 0; fn() { n += 1 } }; let a = make(); let b = make(); a(); a(); b()", 1},
+		{"let f = fn(n) { let inc = fn() { n += 1 }; inc(); inc(); n }; f(10)", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, runVM(t, tt.input), tt.expected)
+	}
+}
+
+// 評価器と同じ結果になることを確認する
+func TestSameResultsAsEvaluator(t *testing.T) {
+	inputs := []string{
+		"true == true",
+		"1 < 2",
+		"!5",
+		"if (false) { 10 }",
+		"if (1 > 2) { 10 } else { 20 }",
+		`"Hello" + " " + "World!"`,
+		"[1, 2 * 2, 3 + 3]",
+		"[1, 2, 3][1 + 1]",
+		"[1, 2, 3][3]",
+		`{"one": 1, "two": 2}["two"]`,
+		`{}["foo"]`,
+		`len("four")`,
+		`first([1, 2, 3])`,
+		`rest([1, 2, 3])`,
+		`push([], 1)`,
+		`let map = fn(arr, f) {
+  let iter = fn(arr, accumulated) {
+    if (len(arr) == 0) { accumulated } else { iter(rest(arr), push(accumulated, f(first(arr)))); }
+  };
+  iter(arr, []);
+};
+map([1, 2, 3, 4], fn(x) { x * 2 });`,
+		"5 + true;",
+		"-true",
+		"true + false; 5",
+		`"Hello" - "World"`,
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		`len(1)`,
+		`len("one", "two")`,
+		"1(2)",
+		"let add = fn(a, b) { a + b }; add(1);",
+		"1 / 0",
+		"1.5 * 2 + 1",
+		"-0.5 < 0",
+		"3 / 2.0",
+		"1.0 / 0",
+		"int(2.5) + float(1)",
+		"1 <= 2",
+		"2 >= 3",
+		"2.5 >= 2",
+		"7 % 3",
+		"7.5 % 2",
+		"7 % 0",
+		"true && false",
+		"1 && 2",
+		"false || 0",
+		"false && (1 / 0)",
+		"true || (1 / 0)",
+		"false || (1 / 0)",
+		"if (1 < 2 && 2 < 3) { 10 } else { 20 }",
+		"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }",
+		"if (1 > 2) { 10 } else if (2 > 3) { 20 }",
+		`{"b": 1, "a": 2, "c": 3}`,
+		`{3: "x", 1: "y", 2: "z", 1: "w"}`,
+		`{"b": 1, "a": 2}["a"]`,
+		"let x = 1; x = 2; x",
+		"let x = 1; let y = 1; x = y = 5; x + y",
+		"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x",
+		"let x = 1; let f = fn() { let x = 10; x = 20; x }; f() + x",
+		"let x = 1; let f = fn() { x += 1 }; f(); f(); x",
+		"let a = [1, 2, 3]; a[0] = 10; a[2] *= 3; a",
+		`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h`,
+		"let a = [1]; a[1] = 2",
+		"let x = 1; x /= 0",
+		`let h = {}; h["k"] += 1`,
+		"let h = {}; h[fn() {}] = 1",
+		"let x = 1; x[0] = 1",
+		"let i = 0; while (i < 5) { i += 1; }; i",
+		"let i = 0; while (false) { i += 1; }; i",
+		"let i = 0; while (true) { i += 1; if (i == 3) { break; } }; i",
+		"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } sum += x; }; sum",
+		"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } sum += x; }; sum",
+		`let s = ""; for (k in {"b": 2, "a": 1}) { s += k; }; s`,
+		`let s = ""; for (ch in "モンキー") { s = ch + s; }; s`,
+		"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } }; f()",
+		"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } n += 1; } }; n",
+		"let f = fn(n) { let i = 0; let sum = 0; while (i < n) { i += 1; sum += i; }; sum }; f(10)",
+		"let a = [1, 2, 3]; for (x in a) { a = push(a, x); }; a",
+		"let x = 0; for (x in [7, 8]) { }; x",
+		"for (x in 5) { x }",
+		"while (1 + true) { }",
+		"let counter = fn() { let n = 0; fn() { n += 1 } }(); counter(); counter()",
+		"let f = fn() { let n = 1; let g = fn() { n }; n = 2; g() }; f()",
+		"let f = fn() { let n = 1; let g = fn() { n }; let n = 2; g() }; f()",
+		"let f = fn() { let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }); }; fs[0]() }; f()",
+		"let f = fn() { let n = 3; let g = fn() { fn() { n = n * 2 } }; g()(); n }; f()",
+		"let f = fn() { let n = 0; let add = fn() { fn(x) { n += x } }(); add(2); add(3); n }; f()",
+		"let f = fn() { let g = fn() { g = 5; 1 }; g() + g }; f()",
+		"let g = fn() { g = 5; 1 }; g() + g",
+	}
+
+	for _, input := range inputs {
+		want := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		got := runVM(t, input)
+
+		if want.Type() != got.Type() || describe(want) != describe(got) {
+			t.Errorf("result differs from evaluator. input=%q, want=%s(%s), got=%s(%s)",
+				input, want.Type(), describe(want), got.Type(), describe(got))
+		}
+	}
+}
+
+// VMのエラーはソースコードの位置を持たないため、メッセージだけを比較する
+func describe(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return err.Message
+	}
+	return obj.Inspect()
+}
+
+func TestExecContext(t *testing.T) {
+	c := compiler.NewCompiler()
+	if err := c.Compile(parse(t, `puts("hello", 1)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var stdout bytes.Buffer
+	machine := vm.NewVM(c.Bytecode())
+	machine.SetExecContext(object.NewExecContext(nil, &stdout, nil))
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if stdout.String() != "hello\n1\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+}
+
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	c := compiler.NewCompiler()
+	if err := c.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.NewVM(c.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+// 構文解析エラーがあればテストを止める
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has %d errors. input=%q, errors=%v", len(p.Errors()), input, p.Errors())
+	}
+	return program
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	}
+}